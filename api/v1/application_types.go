@@ -0,0 +1,170 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApplicationSpec defines the desired state of Application
+type ApplicationSpec struct {
+	// Version is the release of the application. Every component runs its
+	// image tagged with it.
+	// +kubebuilder:validation:MinLength=1
+	Version string `json:"version"`
+
+	// Domain is the host name every component is served under.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Domain string `json:"domain,omitempty"`
+
+	// EnvironmentVariables are set on every component. Variables of a
+	// component replace those of the same name.
+	// +optional
+	EnvironmentVariables []EnvironmentVariable `json:"environmentVariables,omitempty"`
+
+	// Components are the frontends released together.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=name
+	Components []ApplicationComponent `json:"components"`
+
+	// ProgressDeadlineSeconds is how long the components may take to become
+	// ready after a change before every component is rolled back. Defaults
+	// to 600.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+}
+
+// ApplicationComponent is a frontend of an application, deployed as a
+// FrontendDeploy named <application>-<component>.
+type ApplicationComponent struct {
+	// Name of the component.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Image is the image of the component without a tag, such as
+	// registry.example.com/shop/admin.
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Port the component listens on.
+	Port int32 `json:"port"`
+
+	// Replicas of the component.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// IsHost serves the component at the root path.
+	// +optional
+	IsHost bool `json:"isHost,omitempty"`
+
+	// EnvironmentVariables of the component, on top of the shared ones.
+	// +optional
+	EnvironmentVariables []EnvironmentVariable `json:"environmentVariables,omitempty"`
+}
+
+// ApplicationStatus defines the observed state of Application
+type ApplicationStatus struct {
+	// Conditions describe the latest observations of the application.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Version is the last version every component was ready with.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// ReleaseGeneration is the generation of the spec released last.
+	// +optional
+	ReleaseGeneration int64 `json:"releaseGeneration,omitempty"`
+
+	// ReleaseStartTime is when the components were updated to a spec they
+	// aren't all ready with yet. The progress deadline counts from it.
+	// +optional
+	ReleaseStartTime *metav1.Time `json:"releaseStartTime,omitempty"`
+
+	// RolledBackGeneration is the generation that was rolled back. Its spec
+	// isn't applied again, a change to the application releases it anew.
+	// +optional
+	RolledBackGeneration int64 `json:"rolledBackGeneration,omitempty"`
+
+	// Components is the state of every component.
+	// +optional
+	Components []ApplicationComponentStatus `json:"components,omitempty"`
+}
+
+// ApplicationComponentStatus is the state of a component.
+type ApplicationComponentStatus struct {
+	// Name of the component.
+	Name string `json:"name"`
+
+	// FrontendName is the FrontendDeploy of the component.
+	FrontendName string `json:"frontendName"`
+
+	// Ready is true once the FrontendDeploy runs the released spec.
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// StableRevision is the revision of the FrontendDeploy the component was
+	// last ready with, along with every other component. A rollback returns
+	// to it.
+	// +optional
+	StableRevision int64 `json:"stableRevision,omitempty"`
+}
+
+const (
+	// ApplicationConditionReady is True once every component runs the
+	// current spec. It is False with reason Progressing while they roll out,
+	// RolledBack once they were rolled back after the progress deadline, or
+	// ProgressDeadlineExceeded when the first release has nothing to roll
+	// back to. NameTaken tells that a FrontendDeploy not owned by the
+	// application has the name of a component, Rejected that the API server
+	// refuses one, and ComponentNotReady that a component failed after the
+	// release.
+	ApplicationConditionReady = "Ready"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.version`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+
+// Application is the Schema for the applications API
+type Application struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationSpec   `json:"spec,omitempty"`
+	Status ApplicationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ApplicationList contains a list of Application
+type ApplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Application `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Application{}, &ApplicationList{})
+}
@@ -18,6 +18,7 @@ package v1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -40,14 +41,273 @@ type FrontendDeploySpec struct {
 	NodeName              string                `json:"nodeName,omitempty"`
 	IsHost                bool                  `json:"isHost,omitempty"`
 	EnvironmentVarialbles []EnvironmentVariable `json:"environmentVariables,omitempty"`
+
+	// RateLimit limits the requests and connections a single client can make to
+	// this frontend. Fields left unset fall back to the SandOpsIngress defaults.
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+
+	// CORS lets browsers call this frontend from other origins.
+	// +optional
+	CORS *CORSPolicy `json:"cors,omitempty"`
+
+	// ResponseHeaders are added to every response, for example
+	// Content-Security-Policy or X-Frame-Options.
+	// +kubebuilder:validation:XValidation:rule="self.all(k, k.matches('^[A-Za-z0-9!#$%&\\'*+.^_`|~-]+$'))",message="header names must be valid HTTP tokens"
+	// +optional
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+
+	// Host restricts the frontend to requests for this host name, such as
+	// shop.example.com. A frontend with a host gets an Ingress of its own.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// ExtraResources are further objects deployed with the frontend, such as a
+	// ServiceMonitor or a KEDA ScaledObject. They are created in the namespace
+	// of the frontend and owned by it, and deleted once removed from the list.
+	// Kinds that aren't installed in the cluster, objects the operator
+	// generates and existing objects the frontend doesn't control are
+	// rejected.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:EmbeddedResource
+	// +optional
+	ExtraResources []runtime.RawExtension `json:"extraResources,omitempty"`
+
+	// Maintenance answers every request to the frontend with the maintenance
+	// page of the tenant and status 503. The frontend keeps running.
+	// +optional
+	Maintenance bool `json:"maintenance,omitempty"`
+	// Suspend stops the operator from reconciling the frontend, for example
+	// during incident work. The objects it created are left as they are.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Hibernate scales the frontend to zero replicas, keeping its
+	// configuration. The frontend also hibernates with its tenant.
+	// +optional
+	Hibernate bool `json:"hibernate,omitempty"`
+
+	// HibernationSchedule hibernates the frontend during recurring windows,
+	// such as nights and weekends.
+	// +optional
+	HibernationSchedule *HibernationSchedule `json:"hibernationSchedule,omitempty"`
+
+	// IdlePolicy scales the frontend to zero once it served no request for a
+	// while. Its route then points at the operator's activator, which scales
+	// it back up on the first request and holds that request until a replica
+	// is ready.
+	// +optional
+	IdlePolicy *IdlePolicy `json:"idlePolicy,omitempty"`
+
+	// RevisionHistoryLimit is how many revisions of the frontend are kept to
+	// roll back to. Defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// RollbackTo restores the spec recorded in the given revision. The
+	// operator clears it once the spec is restored, which then runs as a new
+	// revision.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RollbackTo *int64 `json:"rollbackTo,omitempty"`
+}
+
+// IdlePolicy tells when a frontend is idle and how long a request may wait for
+// it to wake up.
+type IdlePolicy struct {
+	// IdleMinutes is how long the frontend may go without requests before it
+	// is scaled to zero.
+	// +kubebuilder:validation:Minimum=1
+	IdleMinutes int32 `json:"idleMinutes"`
+
+	// WakeTimeoutSeconds is how long the activator holds a request while the
+	// frontend scales up before answering 504. Defaults to 120.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	WakeTimeoutSeconds int32 `json:"wakeTimeoutSeconds,omitempty"`
+}
+
+// RateLimit maps onto the ingress-nginx limit-* annotations.
+type RateLimit struct {
+	// RequestsPerSecond is the number of requests accepted per second from a single client IP.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RequestsPerSecond int32 `json:"requestsPerSecond,omitempty"`
+
+	// BurstMultiplier multiplies RequestsPerSecond to get the burst size.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BurstMultiplier int32 `json:"burstMultiplier,omitempty"`
+
+	// Connections is the number of concurrent connections allowed from a single client IP.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Connections int32 `json:"connections,omitempty"`
+
+	// WhitelistCIDRs are client ranges that are never rate limited.
+	// +optional
+	WhitelistCIDRs []string `json:"whitelistCIDRs,omitempty"`
+}
+
+// CORSPolicy maps onto the ingress-nginx cors-* annotations.
+type CORSPolicy struct {
+	// AllowOrigins are the origins allowed to call the frontend. Defaults to "*".
+	// +optional
+	AllowOrigins []string `json:"allowOrigins,omitempty"`
+
+	// AllowMethods are the methods allowed in cross-origin requests.
+	// +optional
+	AllowMethods []string `json:"allowMethods,omitempty"`
+
+	// AllowHeaders are the request headers allowed in cross-origin requests.
+	// +optional
+	AllowHeaders []string `json:"allowHeaders,omitempty"`
+
+	// ExposeHeaders are the response headers the browser may read.
+	// +optional
+	ExposeHeaders []string `json:"exposeHeaders,omitempty"`
+
+	// AllowCredentials lets cross-origin requests carry cookies and auth headers.
+	// +optional
+	AllowCredentials bool `json:"allowCredentials,omitempty"`
+
+	// MaxAge is how long, in seconds, the browser may cache a preflight response.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxAge int32 `json:"maxAge,omitempty"`
+}
+
+// HibernationSchedule lists the weekly windows during which a frontend or a
+// tenant hibernates.
+type HibernationSchedule struct {
+	// TimeZone is the IANA time zone the windows are in, such as
+	// Europe/Berlin. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Windows are the recurring hibernation windows.
+	// +kubebuilder:validation:MinItems=1
+	Windows []HibernationWindow `json:"windows"`
+}
+
+// HibernationWindow is a recurring window, starting at Start on each of Days.
+// A window whose End is not after its Start ends on the next day, so 19:00 to
+// 07:00 covers a night and 00:00 to 00:00 a whole day.
+type HibernationWindow struct {
+	// Days are the days of the week the window starts on.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:items:Enum=Mon;Tue;Wed;Thu;Fri;Sat;Sun
+	// +listType=set
+	Days []string `json:"days"`
+
+	// Start is the local time the window starts at, as HH:MM.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// End is the local time the window ends at, as HH:MM.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	End string `json:"end"`
 }
 
 // FrontendDeployStatus defines the observed state of FrontendDeploy
 type FrontendDeployStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// Conditions describe the latest observations of the frontend.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ExtraResources are the extra resources applied for the frontend. Those no
+	// longer in the spec are deleted on the next reconcile.
+	// +optional
+	ExtraResources []ExtraResourceReference `json:"extraResources,omitempty"`
+
+	// Idle tracks the traffic of a frontend with an idle policy.
+	// +optional
+	Idle *IdleStatus `json:"idle,omitempty"`
+
+	// UpdateRevision is the revision recorded for the current spec.
+	// +optional
+	UpdateRevision int64 `json:"updateRevision,omitempty"`
+
+	// CurrentRevision is the revision last seen serving with every replica
+	// available.
+	// +optional
+	CurrentRevision int64 `json:"currentRevision,omitempty"`
+}
+
+// IdleStatus is the traffic last seen for a frontend with an idle policy.
+type IdleStatus struct {
+	// Requests is the request count last read from the ingress controller
+	// metrics. A change means the frontend served requests since.
+	// +optional
+	Requests int64 `json:"requests,omitempty"`
+
+	// LastRequestTime is when the frontend was last seen serving a request,
+	// or last woken up by the activator.
+	// +optional
+	LastRequestTime metav1.Time `json:"lastRequestTime,omitempty"`
+}
+
+// ExtraResourceReference identifies an object applied from spec.extraResources
+// in the namespace of the frontend.
+type ExtraResourceReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
 }
 
+// HTTPTokenPattern matches an HTTP field name, a token of RFC 9110 tchar. The
+// CEL rule on ResponseHeaders repeats it with the quote escaped.
+const HTTPTokenPattern = "^[A-Za-z0-9!#$%&'*+.^_`|~-]+$"
+
+const (
+	// FrontendConditionValid is False when the spec can't be rendered into
+	// Kubernetes objects, for example because of an invalid header name.
+	FrontendConditionValid = "Valid"
+
+	// FrontendConditionReady is True once every replica of the frontend runs
+	// the current spec. Until it first becomes True the reason is Deploying,
+	// afterwards Unavailable.
+	FrontendConditionReady = "Ready"
+
+	// FrontendConditionStalled is True while an object of the frontend fails
+	// in a way retrying can't fix, such as an object the API server rejects.
+	// The operator stops retrying until the FrontendDeploy changes.
+	FrontendConditionStalled = "Stalled"
+
+	// FrontendConditionWaitingForIngress is True while the tenant has no
+	// SandOpsIngress to route the frontend through, or it is being deleted.
+	// The frontend is reconciled again as soon as the SandOpsIngress appears.
+	FrontendConditionWaitingForIngress = "WaitingForIngress"
+
+	// FrontendConditionRouted is True while the frontend has a path on an
+	// Ingress. The path is only published once the Deployment has an available
+	// replica and withdrawn while none is, the reason tells which. In
+	// maintenance the path is kept with reason Maintenance, and a frontend with
+	// an idle policy keeps it pointed at the activator with reason
+	// WakeOnRequest.
+	FrontendConditionRouted = "Routed"
+
+	// FrontendConditionSuspended is True while spec.suspend stops the
+	// operator from reconciling the frontend.
+	FrontendConditionSuspended = "Suspended"
+
+	// FrontendConditionHibernated is True while the frontend is scaled to zero,
+	// because of spec.hibernate, a schedule window or its tenant. The reason
+	// tells which.
+	FrontendConditionHibernated = "Hibernated"
+
+	// FrontendConditionIdle is True while a frontend with an idle policy is
+	// scaled to zero for lack of traffic and routed through the activator.
+	FrontendConditionIdle = "Idle"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
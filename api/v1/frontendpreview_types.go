@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FrontendPreviewSpec defines the desired state of FrontendPreview
+// +kubebuilder:validation:XValidation:rule="has(self.ttl) || has(self.expiresAt)",message="a preview needs a ttl or an expiresAt"
+type FrontendPreviewSpec struct {
+	// BaseRef is the name of the FrontendDeploy in the same namespace the
+	// preview is cloned from. Later changes to it are carried over.
+	// +kubebuilder:validation:MinLength=1
+	BaseRef string `json:"baseRef"`
+
+	// ImageName replaces the image of the base frontend.
+	ImageName string `json:"imageName"`
+
+	// EnvironmentVariables are set on top of those of the base frontend,
+	// replacing variables of the same name.
+	// +optional
+	EnvironmentVariables []EnvironmentVariable `json:"environmentVariables,omitempty"`
+
+	// TTL is how long the preview lives after it was created, such as 72h.
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// ExpiresAt is when the preview is deleted. With a TTL as well, the
+	// earlier of the two applies.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// FrontendPreviewStatus defines the observed state of FrontendPreview
+type FrontendPreviewStatus struct {
+	// Conditions describe the latest observations of the preview.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// FrontendName is the FrontendDeploy serving the preview.
+	// +optional
+	FrontendName string `json:"frontendName,omitempty"`
+
+	// Path is the path of the preview on the tenant ingress controller.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// URL is where the preview can be reached, under the host of the base
+	// frontend or, without one, once the tenant ingress controller has an
+	// external address.
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// ExpiresAt is when the preview and its frontend are deleted.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+const (
+	// FrontendPreviewConditionReady mirrors the Ready condition of the
+	// preview frontend. It is False with reason BaseNotFound while the base
+	// FrontendDeploy doesn't exist, and NameTaken while a FrontendDeploy not
+	// owned by the preview has the name of its frontend.
+	FrontendPreviewConditionReady = "Ready"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Base",type=string,JSONPath=`.spec.baseRef`
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`
+// +kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.status.expiresAt`
+
+// FrontendPreview is the Schema for the frontendpreviews API
+type FrontendPreview struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FrontendPreviewSpec   `json:"spec,omitempty"`
+	Status FrontendPreviewStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// FrontendPreviewList contains a list of FrontendPreview
+type FrontendPreviewList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FrontendPreview `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FrontendPreview{}, &FrontendPreviewList{})
+}
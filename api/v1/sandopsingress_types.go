@@ -30,14 +30,255 @@ type SandOpsIngressSpec struct {
 
 	// Foo is an example field of SandOpsIngress. Edit sandopsingress_types.go to remove/update
 	Foo string `json:"foo,omitempty"`
+
+	// RateLimit holds the tenant-wide limits applied to every frontend of the
+	// tenant. A FrontendDeploy can override each field.
+	// +optional
+	RateLimit *TenantRateLimit `json:"rateLimit,omitempty"`
+
+	// Snippets controls the ingress-nginx *-snippet annotations. They are
+	// rejected unless Snippets is set with Enabled true.
+	// +optional
+	Snippets *SnippetPolicy `json:"snippets,omitempty"`
+
+	// NginxConfig is copied into the tenant's ingress-nginx-controller
+	// ConfigMap. Keys the operator manages itself are rejected. Snippet keys
+	// such as http-snippet are dropped unless snippets are enabled, and stall
+	// the tenant when they use a directive outside the allowed ones.
+	// +kubebuilder:validation:XValidation:rule="self.all(k, !(k in ['allow-snippet-annotations', 'global-allowed-response-headers', 'limit-req-status-code', 'limit-conn-status-code', 'custom-http-errors']))",message="key is managed by the operator"
+	// +optional
+	NginxConfig map[string]string `json:"nginxConfig,omitempty"`
+
+	// NginxSettings are typed shortcuts for common ConfigMap keys. They win
+	// over the same keys in NginxConfig.
+	// +optional
+	NginxSettings *NginxSettings `json:"nginxSettings,omitempty"`
+
+	// TCPServices exposes TCP backends, for example databases or MQTT brokers,
+	// on ports of the tenant load balancer.
+	// +listType=map
+	// +listMapKey=port
+	// +optional
+	TCPServices []PortService `json:"tcpServices,omitempty"`
+
+	// UDPServices exposes UDP backends on ports of the tenant load balancer.
+	// +listType=map
+	// +listMapKey=port
+	// +optional
+	UDPServices []PortService `json:"udpServices,omitempty"`
+
+	// DriftPolicy decides what happens to generated objects that were changed
+	// by hand. Repair, the default, reverts them. ReportOnly lists them in
+	// status.drift and leaves them alone.
+	// +kubebuilder:validation:Enum=Repair;ReportOnly
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// Maintenance answers every request to the frontends of the tenant with
+	// the maintenance page and status 503.
+	// +optional
+	Maintenance bool `json:"maintenance,omitempty"`
+
+	// MaintenancePage is the HTML served while the tenant or one of its
+	// frontends is in maintenance. Defaults to a plain page.
+	// +optional
+	MaintenancePage string `json:"maintenancePage,omitempty"`
+
+	// CustomErrorPages replaces the bodies of error responses of the tenant's
+	// frontends with a page of the tenant.
+	// +optional
+	CustomErrorPages *CustomErrorPages `json:"customErrorPages,omitempty"`
+
+	// Suspend stops the operator from reconciling the tenant ingress
+	// controller. The objects it created are left as they are, and deleting
+	// the SandOpsIngress still cleans them up.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Hibernate scales the tenant ingress controller and every frontend of
+	// the tenant to zero replicas, keeping their configuration.
+	// +optional
+	Hibernate bool `json:"hibernate,omitempty"`
+
+	// HibernationSchedule hibernates the tenant during recurring windows,
+	// such as nights and weekends.
+	// +optional
+	HibernationSchedule *HibernationSchedule `json:"hibernationSchedule,omitempty"`
+}
+
+// CustomErrorPages maps onto the ingress-nginx custom-http-errors setting and
+// the default backend of the tenant ingress controller.
+type CustomErrorPages struct {
+	// Codes are the status codes whose responses are replaced. The client
+	// still receives the original status code.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:items:Minimum=400
+	// +kubebuilder:validation:items:Maximum=599
+	// +listType=set
+	Codes []int32 `json:"codes"`
+
+	// Page is the HTML served for those codes and for requests no frontend
+	// matches. Defaults to a plain page.
+	// +optional
+	Page string `json:"page,omitempty"`
+}
+
+// DriftPolicy is how the operator treats drifted objects.
+type DriftPolicy string
+
+const (
+	DriftPolicyRepair     DriftPolicy = "Repair"
+	DriftPolicyReportOnly DriftPolicy = "ReportOnly"
+)
+
+// PortService maps a port of the tenant load balancer to a backend Service.
+// The ports ingress-nginx listens on itself can't be mapped.
+// +kubebuilder:validation:XValidation:rule="!(self.port in [80, 443, 8181, 8443, 10245, 10246, 10247, 10254])",message="port is used by the ingress controller"
+type PortService struct {
+	// Port is opened on the load balancer and the ingress controller.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// ServiceName is the backend Service.
+	// +kubebuilder:validation:MinLength=1
+	ServiceName string `json:"serviceName"`
+
+	// ServiceNamespace of the backend Service. Defaults to the tenant
+	// namespace, the only namespace allowed.
+	// +optional
+	ServiceNamespace string `json:"serviceNamespace,omitempty"`
+
+	// ServicePort is the port of the backend Service.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ServicePort int32 `json:"servicePort"`
+
+	// ProxyProtocol decodes and encodes the PROXY protocol on the
+	// connection. Only used for TCP.
+	// +optional
+	ProxyProtocol bool `json:"proxyProtocol,omitempty"`
+}
+
+// NginxSettings maps onto keys of the ingress-nginx ConfigMap.
+type NginxSettings struct {
+	// LogFormat sets log-format-upstream.
+	// +optional
+	LogFormat string `json:"logFormat,omitempty"`
+
+	// UseForwardedHeaders trusts X-Forwarded-* headers sent by a load balancer in front of the tenant.
+	// +optional
+	UseForwardedHeaders *bool `json:"useForwardedHeaders,omitempty"`
+
+	// ProxyRealIPCIDRs are the ranges of trusted proxies, see proxy-real-ip-cidr.
+	// +optional
+	ProxyRealIPCIDRs []string `json:"proxyRealIPCIDRs,omitempty"`
+
+	// Gzip sets use-gzip.
+	// +optional
+	Gzip *bool `json:"gzip,omitempty"`
+
+	// Brotli sets enable-brotli.
+	// +optional
+	Brotli *bool `json:"brotli,omitempty"`
+
+	// SSLProtocols sets ssl-protocols, for example ["TLSv1.2", "TLSv1.3"].
+	// +optional
+	SSLProtocols []string `json:"sslProtocols,omitempty"`
+
+	// WorkerProcesses sets worker-processes, a number or "auto".
+	// +kubebuilder:validation:Pattern=`^(auto|[1-9][0-9]*)$`
+	// +optional
+	WorkerProcesses string `json:"workerProcesses,omitempty"`
+
+	// MaxWorkerConnections sets max-worker-connections.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxWorkerConnections int32 `json:"maxWorkerConnections,omitempty"`
+
+	// WorkerShutdownTimeout sets worker-shutdown-timeout, for example "240s".
+	// +optional
+	WorkerShutdownTimeout string `json:"workerShutdownTimeout,omitempty"`
+}
+
+// SnippetPolicy opts a tenant into snippet annotations.
+type SnippetPolicy struct {
+	// Enabled sets allow-snippet-annotations on the tenant ingress controller.
+	// It only takes effect when the operator serves the webhook that checks
+	// snippet annotations, otherwise snippets stay disabled.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// AllowedDirectives are the nginx directives a snippet may use. Defaults to
+	// a small set of header and caching directives. The webhook checks them on
+	// snippet annotations, the operator on the snippet keys of NginxConfig.
+	// +optional
+	AllowedDirectives []string `json:"allowedDirectives,omitempty"`
+}
+
+// TenantRateLimit is a RateLimit plus the settings ingress-nginx only accepts
+// in its ConfigMap.
+type TenantRateLimit struct {
+	RateLimit `json:",inline"`
+
+	// RejectStatusCode is returned to clients that exceed a limit. Defaults to 429.
+	// +kubebuilder:validation:Minimum=400
+	// +kubebuilder:validation:Maximum=599
+	// +optional
+	RejectStatusCode int32 `json:"rejectStatusCode,omitempty"`
 }
 
 // SandOpsIngressStatus defines the observed state of SandOpsIngress
 type SandOpsIngressStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// Conditions describe the latest observations of the tenant ingress controller.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Drift lists the generated objects that no longer match the
+	// SandOpsIngress and were left alone because of DriftPolicy ReportOnly.
+	// +listType=map
+	// +listMapKey=component
+	// +optional
+	Drift []ComponentDrift `json:"drift,omitempty"`
 }
 
+// ComponentDrift is a generated object changed outside the operator.
+type ComponentDrift struct {
+	// Component is the part of the tenant ingress controller that drifted.
+	Component string `json:"component"`
+
+	// Object is the kind and name of the drifted object.
+	Object string `json:"object"`
+
+	// Fields are the paths of the fields that differ.
+	// +optional
+	Fields []string `json:"fields,omitempty"`
+}
+
+const (
+	// IngressConditionReady is True once every component of the tenant
+	// ingress controller has been applied.
+	IngressConditionReady = "Ready"
+
+	// IngressConditionStalled is True while a component fails in a way
+	// retrying can't fix, such as an object the API server rejects. The
+	// operator stops retrying until the SandOpsIngress changes.
+	IngressConditionStalled = "Stalled"
+
+	// IngressConditionSuspended is True while spec.suspend stops the operator
+	// from reconciling the tenant.
+	IngressConditionSuspended = "Suspended"
+
+	// IngressConditionHibernated is True while the tenant is scaled to zero,
+	// because of spec.hibernate or a schedule window. The reason tells which.
+	IngressConditionHibernated = "Hibernated"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"os"
@@ -33,13 +34,20 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	aasdevv1 "sandtech.io/sand-ops/api/v1"
 	frontendsv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/activator"
 	"sandtech.io/sand-ops/internal/controller"
+	sandopsmetrics "sandtech.io/sand-ops/internal/metrics"
+	"sandtech.io/sand-ops/internal/tracing"
+	"sandtech.io/sand-ops/internal/utils"
+	ingresswebhook "sandtech.io/sand-ops/internal/webhook"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -63,6 +71,10 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var tlsOpts []func(*tls.Config)
+	rateLimiter := controller.DefaultRateLimiterOptions
+	var tracingOpts tracing.Options
+	var activatorAddr string
+	var activatorService string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -73,6 +85,23 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.DurationVar(&rateLimiter.BaseDelay, "requeue-base-delay", rateLimiter.BaseDelay,
+		"Delay before a failed reconcile is retried, doubled on every further failure of the same resource.")
+	flag.DurationVar(&rateLimiter.MaxDelay, "requeue-max-delay", rateLimiter.MaxDelay,
+		"Longest delay between retries of a failed reconcile.")
+	flag.Float64Var(&rateLimiter.QPS, "requeue-qps", rateLimiter.QPS,
+		"Retries per second across all resources of a controller.")
+	flag.IntVar(&rateLimiter.Burst, "requeue-burst", rateLimiter.Burst,
+		"Retries of all resources of a controller allowed in a burst above requeue-qps.")
+	flag.StringVar(&tracingOpts.Endpoint, "otlp-endpoint", "",
+		"host:port of an OTLP gRPC receiver to export traces to. "+
+			"OTEL_EXPORTER_OTLP_ENDPOINT is used when unset, tracing is off when neither is set.")
+	flag.BoolVar(&tracingOpts.Insecure, "otlp-insecure", false,
+		"If set, traces are exported without TLS.")
+	flag.StringVar(&activatorAddr, "activator-bind-address", ":8082",
+		"The address the activator waking up idle frontends binds to, or 0 to disable it.")
+	flag.StringVar(&activatorService, "activator-service", utils.DEFAULT_ACTIVATOR_SERVICE,
+		"Host of the Service in front of the activator, which tenants route idle frontends to.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -81,6 +110,12 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -161,29 +196,84 @@ func main() {
 		os.Exit(1)
 	}
 
-	KubeClientSet, err := controller.GetAllClients()
+	// counts SandOpsIngress and FrontendDeploy objects from the cache on every scrape
+	ctrlmetrics.Registry.MustRegister(sandopsmetrics.NewInventoryCollector(mgr.GetClient()))
+
+	KubeClientSet, err := controller.GetAllClients(mgr.GetConfig())
 	if err != nil {
-		setupLog.Error(err, "unable to get kubeclient")
+		setupLog.Error(err, "unable to build kube clients")
+		os.Exit(1)
 	}
 
 	if err = (&controller.FrontendDeployReconciler{
-		Client:      mgr.GetClient(),
+		Client:      tracing.WrapClient(mgr.GetClient()),
 		Scheme:      mgr.GetScheme(),
 		KubeClients: KubeClientSet,
 		Log:         mgr.GetLogger().WithName("frontend deployment: "),
+		Recorder:    mgr.GetEventRecorderFor("frontenddeploy-controller"),
+		RateLimiter: rateLimiter,
+		// the controller pods are listed uncached, the manager doesn't watch pods
+		RequestCounter: activator.NewNginxRequestCounter(mgr.GetAPIReader()),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "FrontendDeploy")
 		os.Exit(1)
 	}
+	// The webhook server needs a serving certificate, so the snippet webhook
+	// is only served where one is mounted, see config/default. Tenants can't
+	// enable snippets without it.
+	// nolint:goconst
+	enableWebhooks := os.Getenv("ENABLE_WEBHOOKS") == "true"
 	if err = (&controller.SandOpsIngressReconciler{
-		Client:      mgr.GetClient(),
-		Scheme:      mgr.GetScheme(),
-		Log:         mgr.GetLogger().WithName("ingress deployment: "),
-		KubeClients: KubeClientSet,
+		Client:            tracing.WrapClient(mgr.GetClient()),
+		Scheme:            mgr.GetScheme(),
+		Log:               mgr.GetLogger().WithName("ingress deployment: "),
+		KubeClients:       KubeClientSet,
+		Recorder:          mgr.GetEventRecorderFor("sandopsingress-controller"),
+		RateLimiter:       rateLimiter,
+		ActivatorService:  activatorService,
+		SnippetValidation: enableWebhooks,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SandOpsIngress")
 		os.Exit(1)
 	}
+	if err = (&controller.FrontendPreviewReconciler{
+		Client:      tracing.WrapClient(mgr.GetClient()),
+		Scheme:      mgr.GetScheme(),
+		Log:         mgr.GetLogger().WithName("frontend preview: "),
+		Recorder:    mgr.GetEventRecorderFor("frontendpreview-controller"),
+		RateLimiter: rateLimiter,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "FrontendPreview")
+		os.Exit(1)
+	}
+	if err = (&controller.ApplicationReconciler{
+		Client:      tracing.WrapClient(mgr.GetClient()),
+		Scheme:      mgr.GetScheme(),
+		Log:         mgr.GetLogger().WithName("application: "),
+		Recorder:    mgr.GetEventRecorderFor("application-controller"),
+		RateLimiter: rateLimiter,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Application")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		mgr.GetWebhookServer().Register(ingresswebhook.IngressSnippetValidatorPath, &webhook.Admission{
+			Handler: &ingresswebhook.IngressSnippetValidator{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+			},
+		})
+	}
+	if activatorAddr != "0" {
+		if err := mgr.Add(&activator.Server{
+			Client: mgr.GetClient(),
+			Addr:   activatorAddr,
+			Log:    mgr.GetLogger().WithName("activator"),
+		}); err != nil {
+			setupLog.Error(err, "unable to set up activator")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -200,4 +290,7 @@ func main() {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		setupLog.Error(err, "unable to flush traces")
+	}
 }
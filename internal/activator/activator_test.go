@@ -0,0 +1,160 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+)
+
+var _ = Describe("Request counter", func() {
+	It("sums the requests of a backend Service over every series", func() {
+		metrics := `# HELP nginx_ingress_controller_requests The total number of client requests
+# TYPE nginx_ingress_controller_requests counter
+nginx_ingress_controller_requests{namespace="tenant-ns",service="web-svc",status="200"} 12
+nginx_ingress_controller_requests{namespace="tenant-ns",service="web-svc",status="404"} 3
+nginx_ingress_controller_requests{namespace="tenant-ns",service="admin-svc",status="200"} 40
+nginx_ingress_controller_requests{namespace="other-ns",service="web-svc",status="200"} 7
+`
+		requests, err := sumRequests(strings.NewReader(metrics), "tenant-ns", "web-svc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(Equal(int64(15)))
+	})
+
+	It("counts nothing before the first request", func() {
+		requests, err := sumRequests(strings.NewReader("# TYPE nginx_ingress_controller_nginx_process_requests_total counter\nnginx_ingress_controller_nginx_process_requests_total 3\n"), "tenant-ns", "web-svc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(BeZero())
+	})
+})
+
+var _ = Describe("Activator", func() {
+	var (
+		ctx     context.Context
+		c       client.Client
+		server  *Server
+		backend *httptest.Server
+		seen    chan string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		frontend := &aasdevv1.FrontendDeploy{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"},
+			Spec: aasdevv1.FrontendDeploySpec{
+				ImageName:  "nginx",
+				Port:       80,
+				IdlePolicy: &aasdevv1.IdlePolicy{IdleMinutes: 10, WakeTimeoutSeconds: 1},
+			},
+		}
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"}}
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(frontend, deployment).
+			WithStatusSubresource(&aasdevv1.FrontendDeploy{}, &appsv1.Deployment{}).
+			Build()
+
+		seen = make(chan string, 1)
+		backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			seen <- req.Host
+			_, _ = io.WriteString(w, "hello from "+req.URL.Path)
+		}))
+		DeferCleanup(backend.Close)
+		target, err := url.Parse(backend.URL)
+		Expect(err).NotTo(HaveOccurred())
+		server = &Server{
+			Client:       c,
+			Log:          logf.Log,
+			PollInterval: 10 * time.Millisecond,
+			backend:      func(*aasdevv1.FrontendDeploy) *url.URL { return target },
+		}
+	})
+
+	serve := func(host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/index.html", nil)
+		req.Host = host
+		req.Header.Set("X-Forwarded-Host", "shop.example.com")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+	frontend := func() *aasdevv1.FrontendDeploy {
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "web", Namespace: "tenant-ns"}, frontend)).To(Succeed())
+		return frontend
+	}
+
+	It("wakes the frontend, holds the request and proxies it once a replica is available", func() {
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			defer GinkgoRecover()
+			done <- serve("web.tenant-ns")
+		}()
+
+		Eventually(func() *aasdevv1.IdleStatus { return frontend().Status.Idle }).ShouldNot(BeNil())
+		Expect(frontend().Status.Idle.LastRequestTime.Time).To(BeTemporally("~", time.Now(), 5*time.Second))
+		Consistently(done, 100*time.Millisecond).ShouldNot(Receive())
+
+		deployment := &appsv1.Deployment{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "web", Namespace: "tenant-ns"}, deployment)).To(Succeed())
+		deployment.Status.AvailableReplicas = 1
+		Expect(c.Status().Update(ctx, deployment)).To(Succeed())
+
+		var response *httptest.ResponseRecorder
+		Eventually(done).Should(Receive(&response))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Body.String()).To(Equal("hello from /index.html"))
+		Expect(seen).To(Receive(Equal("shop.example.com")))
+	})
+
+	It("answers 504 when the frontend doesn't wake up in time", func() {
+		Expect(serve("web.tenant-ns").Code).To(Equal(http.StatusGatewayTimeout))
+	})
+
+	It("doesn't wake hibernated or unknown frontends", func() {
+		Expect(serve("missing.tenant-ns").Code).To(Equal(http.StatusNotFound))
+		Expect(serve("localhost").Code).To(Equal(http.StatusNotFound))
+
+		hibernated := frontend()
+		meta.SetStatusCondition(&hibernated.Status.Conditions, metav1.Condition{Type: aasdevv1.FrontendConditionHibernated, Status: metav1.ConditionTrue, Reason: "Hibernate"})
+		Expect(c.Status().Update(ctx, hibernated)).To(Succeed())
+		Expect(serve("web.tenant-ns").Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(frontend().Status.Idle).To(BeNil())
+	})
+})
@@ -0,0 +1,114 @@
+package activator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// requestsMetric counts the requests ingress-nginx proxied, labelled with the
+// namespace and Service of the backend.
+const requestsMetric = "nginx_ingress_controller_requests"
+
+// ingressMetricsPort is where ingress-nginx serves its Prometheus metrics.
+const ingressMetricsPort = 10254
+
+// RequestCounter reads how many requests the ingress controllers of a tenant
+// proxied to a Service. The count only grows while the controller pods live,
+// so callers look for a change rather than an increase.
+type RequestCounter interface {
+	Requests(ctx context.Context, namespace, service string) (int64, error)
+}
+
+// NginxRequestCounter scrapes the metrics of the ingress-nginx controller
+// pods running in the tenant namespace.
+type NginxRequestCounter struct {
+	// Reader lists the controller pods. An uncached reader keeps the manager
+	// from watching every pod of the cluster.
+	Reader     client.Reader
+	HTTPClient *http.Client
+}
+
+// NewNginxRequestCounter returns a counter scraping with a short timeout, so a
+// stuck controller pod doesn't hold up a reconcile.
+func NewNginxRequestCounter(reader client.Reader) *NginxRequestCounter {
+	return &NginxRequestCounter{Reader: reader, HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (c *NginxRequestCounter) Requests(ctx context.Context, namespace, service string) (int64, error) {
+	pods := &corev1.PodList{}
+	if err := c.Reader.List(ctx, pods, client.InNamespace(namespace), client.MatchingLabels{
+		"app.kubernetes.io/component": utils.CONTROLLER,
+		"app.kubernetes.io/instance":  utils.INGRESS_NGINX,
+		"app.kubernetes.io/name":      utils.INGRESS_NGINX,
+	}); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.PodIP == "" || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		requests, err := c.scrape(ctx, pod.Status.PodIP, namespace, service)
+		if err != nil {
+			return 0, fmt.Errorf("scraping ingress controller pod %s: %w", pod.Name, err)
+		}
+		total += requests
+	}
+	return total, nil
+}
+
+func (c *NginxRequestCounter) scrape(ctx context.Context, podIP, namespace, service string) (int64, error) {
+	url := "http://" + net.JoinHostPort(podIP, strconv.Itoa(ingressMetricsPort)) + "/metrics"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return sumRequests(resp.Body, namespace, service)
+}
+
+// sumRequests adds up the requests metric of a Prometheus text exposition
+// over every series of the given backend Service.
+func sumRequests(metrics io.Reader, namespace, service string) (int64, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(metrics)
+	if err != nil {
+		return 0, err
+	}
+	family, ok := families[requestsMetric]
+	if !ok {
+		return 0, nil
+	}
+
+	var total float64
+	for _, metric := range family.GetMetric() {
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["namespace"] != namespace || labels["service"] != service {
+			continue
+		}
+		total += metric.GetCounter().GetValue()
+	}
+	return int64(total), nil
+}
@@ -0,0 +1,172 @@
+// Package activator wakes up frontends that were scaled to zero for lack of
+// traffic. The route of an idle frontend points at the activator, which
+// records the request on the FrontendDeploy so the operator scales it back up,
+// holds the request until a replica is available and then proxies it.
+package activator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// wakeWriteInterval bounds how often held requests write the same wake-up to
+// the FrontendDeploy status.
+const wakeWriteInterval = 10 * time.Second
+
+// Server is the activator. It runs on every manager replica, leader or not.
+type Server struct {
+	Client client.Client
+	Addr   string
+	Log    logr.Logger
+
+	// PollInterval is how often a held request checks the Deployment of the
+	// frontend. Defaults to half a second.
+	PollInterval time.Duration
+
+	// backend is where a woken frontend is proxied to, the Service of the
+	// frontend unless a test replaces it.
+	backend func(frontendPod *controllerapi.FrontendDeploy) *url.URL
+}
+
+// Start serves the activator until ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	server := &http.Server{Addr: s.Addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			s.Log.Error(err, "failed to shut down the activator")
+		}
+	}()
+	s.Log.Info("starting activator", "address", s.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection lets every replica serve held requests.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name, namespace, ok := utils.ParseActivatorVirtualHost(req.Host)
+	if !ok {
+		http.Error(w, "unknown frontend", http.StatusNotFound)
+		return
+	}
+	l := s.Log.WithValues("frontend", namespace+"/"+name)
+
+	frontendPod := &controllerapi.FrontendDeploy{}
+	err := s.Client.Get(req.Context(), client.ObjectKey{Name: name, Namespace: namespace}, frontendPod)
+	if apierrors.IsNotFound(err) || (err == nil && frontendPod.Spec.IdlePolicy == nil) {
+		http.Error(w, "unknown frontend", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		l.Error(err, "failed to get frontend")
+		http.Error(w, "failed to get frontend", http.StatusInternalServerError)
+		return
+	}
+	if meta.IsStatusConditionTrue(frontendPod.Status.Conditions, controllerapi.FrontendConditionHibernated) {
+		// hibernation wins over traffic, the activator doesn't wake the frontend
+		http.Error(w, "frontend is hibernated", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := s.wake(req.Context(), frontendPod); err != nil {
+		l.Error(err, "failed to wake frontend")
+		http.Error(w, "failed to wake frontend", http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), utils.WakeTimeout(frontendPod))
+	defer cancel()
+	if err := s.waitAvailable(ctx, frontendPod); err != nil {
+		l.Info("frontend didn't wake up in time", "error", err.Error())
+		http.Error(w, "frontend didn't wake up in time", http.StatusGatewayTimeout)
+		return
+	}
+
+	s.proxy(frontendPod).ServeHTTP(w, req)
+}
+
+// wake records the request in the idle status of the frontend, which makes the
+// operator scale it back up.
+func (s *Server) wake(ctx context.Context, frontendPod *controllerapi.FrontendDeploy) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := s.Client.Get(ctx, client.ObjectKeyFromObject(frontendPod), frontendPod); err != nil {
+			return err
+		}
+		now := metav1.Now()
+		if frontendPod.Status.Idle == nil {
+			frontendPod.Status.Idle = &controllerapi.IdleStatus{}
+		} else if now.Sub(frontendPod.Status.Idle.LastRequestTime.Time) < wakeWriteInterval {
+			return nil
+		}
+		frontendPod.Status.Idle.LastRequestTime = now
+		return s.Client.Status().Update(ctx, frontendPod)
+	})
+}
+
+// waitAvailable returns once the Deployment of the frontend has an available
+// replica, or ctx is done.
+func (s *Server) waitAvailable(ctx context.Context, frontendPod *controllerapi.FrontendDeploy) error {
+	interval := s.PollInterval
+	if interval == 0 {
+		interval = 500 * time.Millisecond
+	}
+	return wait.PollUntilContextCancel(ctx, interval, true, func(ctx context.Context) (bool, error) {
+		deployment := &appsv1.Deployment{}
+		err := s.Client.Get(ctx, client.ObjectKeyFromObject(frontendPod), deployment)
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return deployment.Status.AvailableReplicas > 0, nil
+	})
+}
+
+// proxy forwards the request to the frontend under the host the client asked
+// for, which ingress-nginx passes on in X-Forwarded-Host.
+func (s *Server) proxy(frontendPod *controllerapi.FrontendDeploy) *httputil.ReverseProxy {
+	backend := s.backend
+	if backend == nil {
+		backend = frontendURL
+	}
+	target := backend(frontendPod)
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			// Rewrite drops the forwarding headers, keep the ones ingress-nginx set
+			r.Out.Host = r.In.Header.Get("X-Forwarded-Host")
+			r.Out.Header["X-Forwarded-For"] = r.In.Header["X-Forwarded-For"]
+			r.Out.Header["X-Forwarded-Host"] = r.In.Header["X-Forwarded-Host"]
+			r.Out.Header["X-Forwarded-Proto"] = r.In.Header["X-Forwarded-Proto"]
+		},
+	}
+}
+
+func frontendURL(frontendPod *controllerapi.FrontendDeploy) *url.URL {
+	return &url.URL{
+		Scheme: "http",
+		Host:   utils.FrontendSVCSuffixedString(frontendPod.Name) + "." + frontendPod.Namespace + ".svc:" + strconv.Itoa(int(frontendPod.Spec.Port)),
+	}
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline"
+	"sandtech.io/sand-ops/internal/tracing"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+// defaultProgressDeadline is how long the components of an application may
+// take to become ready before they are rolled back.
+const defaultProgressDeadline = 10 * time.Minute
+
+// ApplicationReconciler reconciles a Application object
+type ApplicationReconciler struct {
+	client.Client
+	Scheme      *runtime.Scheme
+	Log         logr.Logger
+	Recorder    record.EventRecorder
+	RateLimiter RateLimiterOptions
+}
+
+// +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=applications,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=applications/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=applications/finalizers,verbs=update
+
+// Reconcile releases the components of an application together. Every
+// FrontendDeploy is checked before any is changed, and once the components
+// don't all become ready within the progress deadline, every one of them is
+// rolled back to the revision of the last release they were all ready with.
+func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracing.StartReconcile(ctx, "Application", req.NamespacedName)
+	result, err := r.reconcile(ctx, req)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (r *ApplicationReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := r.Log.WithValues("reconciling ", req.NamespacedName)
+
+	application := &controllerapi.Application{}
+	if err := r.Get(ctx, req.NamespacedName, application); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !application.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	status := application.Status.DeepCopy()
+	desired := applicationFrontends(application)
+
+	if status.RolledBackGeneration == application.Generation {
+		// the components stay on the previous release until the spec changes
+		if err := r.readComponents(ctx, desired); err != nil {
+			return ctrl.Result{}, err
+		}
+		setApplicationComponents(application, status, desired)
+		return ctrl.Result{}, r.updateApplicationStatus(ctx, application, status)
+	}
+
+	// every component is checked before any is changed, so a release isn't
+	// left half applied by a component that can't be
+	for _, frontend := range desired {
+		current := &controllerapi.FrontendDeploy{}
+		err := r.Get(ctx, client.ObjectKeyFromObject(frontend), current)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !metav1.IsControlledBy(current, application) {
+			setApplicationReady(application, status, metav1.ConditionFalse, "NameTaken", fmt.Sprintf("FrontendDeploy %s isn't owned by the application", frontend.Name))
+			return ctrl.Result{}, r.updateApplicationStatus(ctx, application, status)
+		}
+	}
+	for _, frontend := range desired {
+		if err := pipeline.ServerSideApplyDryRun(ctx, r.Client, frontend.DeepCopy()); err != nil {
+			l.Error(err, "component rejected", "frontend", frontend.Name)
+			recordFailed(r.Recorder, application, EventReasonApplyFailed, err)
+			if reason, ok := terminalReason(err); ok {
+				setApplicationReady(application, status, metav1.ConditionFalse, reason, err.Error())
+				if err := r.updateApplicationStatus(ctx, application, status); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			return ctrl.Result{}, reconcileError(err)
+		}
+	}
+
+	now := time.Now()
+	if status.ReleaseGeneration != application.Generation {
+		status.ReleaseGeneration = application.Generation
+		status.ReleaseStartTime = &metav1.Time{Time: now}
+	}
+	for _, frontend := range desired {
+		action, err := pipeline.ServerSideApply(ctx, r.Client, frontend)
+		if err != nil {
+			l.Error(err, "failed to apply component", "frontend", frontend.Name)
+			recordFailed(r.Recorder, application, EventReasonApplyFailed, err)
+			return ctrl.Result{}, reconcileError(err)
+		}
+		recordApplied(r.Recorder, application, action, pipeline.ObjectName(r.Client, frontend))
+	}
+
+	// apply returned the components as stored, status included
+	notReady := setApplicationComponents(application, status, desired)
+	if len(notReady) == 0 {
+		status.Version = application.Spec.Version
+		status.ReleaseStartTime = nil
+		for i := range status.Components {
+			status.Components[i].StableRevision = desired[i].Status.CurrentRevision
+		}
+		if err := r.pruneComponents(ctx, application, desired); err != nil {
+			return ctrl.Result{}, err
+		}
+		setApplicationReady(application, status, metav1.ConditionTrue, "Available", "")
+		return ctrl.Result{}, r.updateApplicationStatus(ctx, application, status)
+	}
+
+	waiting := strings.Join(notReady, ", ")
+	if status.ReleaseStartTime == nil {
+		// the release was complete, a component failed on its own since
+		setApplicationReady(application, status, metav1.ConditionFalse, "ComponentNotReady", fmt.Sprintf("not ready: %s", waiting))
+		return ctrl.Result{}, r.updateApplicationStatus(ctx, application, status)
+	}
+	deadline := status.ReleaseStartTime.Add(progressDeadline(application))
+	if now.Before(deadline) {
+		setApplicationReady(application, status, metav1.ConditionFalse, "Progressing", fmt.Sprintf("waiting for %s", waiting))
+		return ctrl.Result{RequeueAfter: deadline.Sub(now)}, r.updateApplicationStatus(ctx, application, status)
+	}
+	if status.Version == "" {
+		// the first release has nothing to return to
+		setApplicationReady(application, status, metav1.ConditionFalse, "ProgressDeadlineExceeded", fmt.Sprintf("not ready within %s: %s", progressDeadline(application), waiting))
+		return ctrl.Result{}, r.updateApplicationStatus(ctx, application, status)
+	}
+
+	l.Info("components not ready within the progress deadline, rolling back", "components", waiting)
+	if err := r.rollbackComponents(ctx, application, status, desired); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Eventf(application, corev1.EventTypeNormal, EventReasonRolledBack, "%s not ready within %s, rolled back to version %s", waiting, progressDeadline(application), status.Version)
+	setApplicationReady(application, status, metav1.ConditionFalse, "RolledBack", fmt.Sprintf("%s not ready within %s, running version %s", waiting, progressDeadline(application), status.Version))
+	return ctrl.Result{}, r.updateApplicationStatus(ctx, application, status)
+}
+
+// applicationFrontends renders the FrontendDeploy of every component, with
+// the version, domain and environment shared by the application.
+func applicationFrontends(application *controllerapi.Application) []*controllerapi.FrontendDeploy {
+	frontends := make([]*controllerapi.FrontendDeploy, 0, len(application.Spec.Components))
+	for _, component := range application.Spec.Components {
+		frontends = append(frontends, &controllerapi.FrontendDeploy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      utils.ApplicationFrontendName(application.Name, component.Name),
+				Namespace: application.Namespace,
+				Labels: map[string]string{
+					utils.APPLICATION_NAME_LABEL: application.Name,
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion:         controllerapi.GroupVersion.String(),
+						Kind:               "Application",
+						Name:               application.Name,
+						UID:                application.UID,
+						Controller:         utils.DataTypePointerRef(true),
+						BlockOwnerDeletion: utils.DataTypePointerRef(true),
+					},
+				},
+			},
+			Spec: controllerapi.FrontendDeploySpec{
+				ImageName:             component.Image + ":" + application.Spec.Version,
+				Port:                  component.Port,
+				Replicas:              component.Replicas,
+				IsHost:                component.IsHost,
+				Host:                  application.Spec.Domain,
+				EnvironmentVarialbles: mergeEnvironment(application.Spec.EnvironmentVariables, component.EnvironmentVariables),
+			},
+		})
+	}
+	return frontends
+}
+
+func progressDeadline(application *controllerapi.Application) time.Duration {
+	if application.Spec.ProgressDeadlineSeconds == nil {
+		return defaultProgressDeadline
+	}
+	return time.Duration(*application.Spec.ProgressDeadlineSeconds) * time.Second
+}
+
+// componentReady reports whether a FrontendDeploy is ready with its current
+// spec, which it has rolled out as its current revision.
+func componentReady(frontend *controllerapi.FrontendDeploy) bool {
+	ready := meta.FindStatusCondition(frontend.Status.Conditions, controllerapi.FrontendConditionReady)
+	return ready != nil &&
+		ready.Status == metav1.ConditionTrue &&
+		ready.ObservedGeneration == frontend.Generation &&
+		frontend.Status.CurrentRevision == frontend.Status.UpdateRevision
+}
+
+// setApplicationComponents lists the components in status, keeping their
+// stable revisions, and returns the names of those not ready.
+func setApplicationComponents(application *controllerapi.Application, status *controllerapi.ApplicationStatus, frontends []*controllerapi.FrontendDeploy) []string {
+	stable := map[string]int64{}
+	for _, component := range status.Components {
+		stable[component.FrontendName] = component.StableRevision
+	}
+	var notReady []string
+	status.Components = make([]controllerapi.ApplicationComponentStatus, 0, len(frontends))
+	for i, frontend := range frontends {
+		ready := componentReady(frontend)
+		if !ready {
+			notReady = append(notReady, frontend.Name)
+		}
+		status.Components = append(status.Components, controllerapi.ApplicationComponentStatus{
+			Name:           application.Spec.Components[i].Name,
+			FrontendName:   frontend.Name,
+			Ready:          ready,
+			StableRevision: stable[frontend.Name],
+		})
+	}
+	return notReady
+}
+
+// readComponents fills in the stored state of the components, leaving those
+// that don't exist as rendered.
+func (r *ApplicationReconciler) readComponents(ctx context.Context, frontends []*controllerapi.FrontendDeploy) error {
+	for _, frontend := range frontends {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(frontend), frontend); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackComponents points spec.rollbackTo of every component at its stable
+// revision and deletes the components the release added. Components the
+// release removed were kept until it was ready, so they still run the
+// previous one.
+func (r *ApplicationReconciler) rollbackComponents(ctx context.Context, application *controllerapi.Application, status *controllerapi.ApplicationStatus, frontends []*controllerapi.FrontendDeploy) error {
+	for i, frontend := range frontends {
+		revision := status.Components[i].StableRevision
+		if revision == 0 {
+			if err := r.Delete(ctx, frontend); client.IgnoreNotFound(err) != nil {
+				recordFailed(r.Recorder, application, EventReasonDeleteFailed, err)
+				return err
+			}
+			recordDeleted(r.Recorder, application, pipeline.ObjectName(r.Client, frontend))
+			continue
+		}
+		frontend.Spec.RollbackTo = &revision
+		if err := r.Update(ctx, frontend); err != nil {
+			return err
+		}
+	}
+	status.RolledBackGeneration = application.Generation
+	status.ReleaseStartTime = nil
+	return nil
+}
+
+// pruneComponents deletes the FrontendDeploys of components no longer listed
+// in the application.
+func (r *ApplicationReconciler) pruneComponents(ctx context.Context, application *controllerapi.Application, frontends []*controllerapi.FrontendDeploy) error {
+	listed := map[string]bool{}
+	for _, frontend := range frontends {
+		listed[frontend.Name] = true
+	}
+	existing := &controllerapi.FrontendDeployList{}
+	if err := r.List(ctx, existing, client.InNamespace(application.Namespace), client.MatchingLabels{utils.APPLICATION_NAME_LABEL: application.Name}); err != nil {
+		return err
+	}
+	for i := range existing.Items {
+		frontend := &existing.Items[i]
+		if listed[frontend.Name] || !metav1.IsControlledBy(frontend, application) {
+			continue
+		}
+		if err := r.Delete(ctx, frontend); client.IgnoreNotFound(err) != nil {
+			recordFailed(r.Recorder, application, EventReasonDeleteFailed, err)
+			return err
+		}
+		recordDeleted(r.Recorder, application, pipeline.ObjectName(r.Client, frontend))
+	}
+	return nil
+}
+
+func setApplicationReady(application *controllerapi.Application, status *controllerapi.ApplicationStatus, conditionStatus metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               controllerapi.ApplicationConditionReady,
+		Status:             conditionStatus,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: application.Generation,
+	})
+}
+
+// updateApplicationStatus writes status when it differs from the one of
+// application.
+func (r *ApplicationReconciler) updateApplicationStatus(ctx context.Context, application *controllerapi.Application, status *controllerapi.ApplicationStatus) error {
+	if equality.Semantic.DeepEqual(&application.Status, status) {
+		return nil
+	}
+	application.Status = *status
+	return r.Status().Update(ctx, application)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{RateLimiter: NewRateLimiter(r.RateLimiter)}).
+		For(&controllerapi.Application{}).
+		Owns(&controllerapi.FrontendDeploy{}).
+		Complete(r)
+}
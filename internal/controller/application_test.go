@@ -0,0 +1,247 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+)
+
+var _ = Describe("Application", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		reconciler *ApplicationReconciler
+		request    reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		application := &aasdevv1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "tenant-ns", UID: "shop-uid", Generation: 1},
+			Spec: aasdevv1.ApplicationSpec{
+				Version: "1.0.0",
+				Domain:  "shop.example.com",
+				EnvironmentVariables: []aasdevv1.EnvironmentVariable{
+					{Name: "API_URL", Value: "https://api.example.com"},
+					{Name: "THEME", Value: "light"},
+				},
+				Components: []aasdevv1.ApplicationComponent{
+					{Name: "shell", Image: "registry.example.com/shell", Port: 80, IsHost: true},
+					{Name: "admin", Image: "registry.example.com/admin", Port: 8080, EnvironmentVariables: []aasdevv1.EnvironmentVariable{{Name: "THEME", Value: "dark"}}},
+				},
+			},
+		}
+		request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(application)}
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(application).
+			WithStatusSubresource(&aasdevv1.Application{}, &aasdevv1.FrontendDeploy{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		reconciler = &ApplicationReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+	})
+
+	application := func() *aasdevv1.Application {
+		application := &aasdevv1.Application{}
+		Expect(c.Get(ctx, request.NamespacedName, application)).To(Succeed())
+		return application
+	}
+	ready := func() *metav1.Condition {
+		return meta.FindStatusCondition(application().Status.Conditions, aasdevv1.ApplicationConditionReady)
+	}
+	component := func(name string) *aasdevv1.FrontendDeploy {
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: name, Namespace: "tenant-ns"}, frontend)).To(Succeed())
+		return frontend
+	}
+	// setComponentReady stands in for the FrontendDeploy controller.
+	setComponentReady := func(name string, revision int64, available bool) {
+		frontend := component(name)
+		conditionStatus := metav1.ConditionFalse
+		if available {
+			conditionStatus = metav1.ConditionTrue
+		}
+		meta.SetStatusCondition(&frontend.Status.Conditions, metav1.Condition{
+			Type:               aasdevv1.FrontendConditionReady,
+			Status:             conditionStatus,
+			Reason:             "Test",
+			ObservedGeneration: frontend.Generation,
+		})
+		frontend.Status.UpdateRevision = revision
+		if available {
+			frontend.Status.CurrentRevision = revision
+		}
+		Expect(c.Status().Update(ctx, frontend)).To(Succeed())
+	}
+	release := func(mutate func(*aasdevv1.ApplicationSpec)) {
+		updated := application()
+		mutate(&updated.Spec)
+		updated.Generation++
+		Expect(c.Update(ctx, updated)).To(Succeed())
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+	}
+	events := func() []string {
+		var recorded []string
+		for {
+			select {
+			case event := <-reconciler.Recorder.(*record.FakeRecorder).Events:
+				recorded = append(recorded, event)
+			default:
+				return recorded
+			}
+		}
+	}
+
+	It("creates the components with the shared version, domain and environment", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		shell := component("shop-shell")
+		Expect(shell.Spec.ImageName).To(Equal("registry.example.com/shell:1.0.0"))
+		Expect(shell.Spec.Host).To(Equal("shop.example.com"))
+		Expect(shell.Spec.IsHost).To(BeTrue())
+		Expect(metav1.IsControlledBy(shell, application())).To(BeTrue())
+
+		admin := component("shop-admin")
+		Expect(admin.Spec.ImageName).To(Equal("registry.example.com/admin:1.0.0"))
+		Expect(admin.Spec.Port).To(Equal(int32(8080)))
+		Expect(admin.Spec.EnvironmentVarialbles).To(ConsistOf(
+			aasdevv1.EnvironmentVariable{Name: "API_URL", Value: "https://api.example.com"},
+			aasdevv1.EnvironmentVariable{Name: "THEME", Value: "dark"},
+		))
+	})
+
+	It("is ready once every component is", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready().Reason).To(Equal("Progressing"))
+		Expect(application().Status.ReleaseStartTime).NotTo(BeNil())
+
+		setComponentReady("shop-shell", 1, true)
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(ready().Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready().Message).To(ContainSubstring("shop-admin"))
+
+		setComponentReady("shop-admin", 1, true)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready().Status).To(Equal(metav1.ConditionTrue))
+		status := application().Status
+		Expect(status.Version).To(Equal("1.0.0"))
+		Expect(status.ReleaseStartTime).To(BeNil())
+		Expect(status.Components).To(ConsistOf(
+			aasdevv1.ApplicationComponentStatus{Name: "shell", FrontendName: "shop-shell", Ready: true, StableRevision: 1},
+			aasdevv1.ApplicationComponentStatus{Name: "admin", FrontendName: "shop-admin", Ready: true, StableRevision: 1},
+		))
+	})
+
+	It("changes no component while one can't be applied", func() {
+		Expect(c.Create(ctx, &aasdevv1.FrontendDeploy{ObjectMeta: metav1.ObjectMeta{Name: "shop-admin", Namespace: "tenant-ns"}})).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready().Reason).To(Equal("NameTaken"))
+		err = c.Get(ctx, client.ObjectKey{Name: "shop-shell", Namespace: "tenant-ns"}, &aasdevv1.FrontendDeploy{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("rolls back every component when one isn't ready by the deadline", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		setComponentReady("shop-shell", 1, true)
+		setComponentReady("shop-admin", 1, true)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready().Status).To(Equal(metav1.ConditionTrue))
+
+		deadline := int32(60)
+		release(func(spec *aasdevv1.ApplicationSpec) {
+			spec.Version = "1.1.0"
+			spec.ProgressDeadlineSeconds = &deadline
+			spec.Components = append(spec.Components, aasdevv1.ApplicationComponent{Name: "checkout", Image: "registry.example.com/checkout", Port: 80})
+		})
+		Expect(component("shop-shell").Spec.ImageName).To(Equal("registry.example.com/shell:1.1.0"))
+		setComponentReady("shop-shell", 2, true)
+		setComponentReady("shop-admin", 2, false)
+		setComponentReady("shop-checkout", 1, true)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready().Reason).To(Equal("Progressing"))
+		events()
+
+		// the deadline passes
+		expired := application()
+		expired.Status.ReleaseStartTime = &metav1.Time{Time: time.Now().Add(-2 * time.Minute)}
+		Expect(c.Status().Update(ctx, expired)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(ready().Reason).To(Equal("RolledBack"))
+		Expect(ready().Message).To(ContainSubstring("shop-admin"))
+		Expect(application().Status.Version).To(Equal("1.0.0"))
+		Expect(component("shop-shell").Spec.RollbackTo).To(HaveValue(Equal(int64(1))))
+		Expect(component("shop-admin").Spec.RollbackTo).To(HaveValue(Equal(int64(1))))
+		err = c.Get(ctx, client.ObjectKey{Name: "shop-checkout", Namespace: "tenant-ns"}, &aasdevv1.FrontendDeploy{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(events()).To(ContainElement(HavePrefix("Normal RolledBack")))
+
+		// the rolled back spec isn't applied again
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		err = c.Get(ctx, client.ObjectKey{Name: "shop-checkout", Namespace: "tenant-ns"}, &aasdevv1.FrontendDeploy{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(ready().Reason).To(Equal("RolledBack"))
+	})
+
+	It("deletes removed components once the release is ready", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		release(func(spec *aasdevv1.ApplicationSpec) { spec.Components = spec.Components[:1] })
+		component("shop-admin")
+
+		setComponentReady("shop-shell", 1, true)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready().Status).To(Equal(metav1.ConditionTrue))
+		err = c.Get(ctx, client.ObjectKey{Name: "shop-admin", Namespace: "tenant-ns"}, &aasdevv1.FrontendDeploy{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// errUnchanged is returned by a reconcile step whose object was already up to
+// date. It is not a failure.
+var errUnchanged = errors.New("unchanged")
+
+// errWaitingForIngress is returned by the ingress step while the tenant has no
+// SandOpsIngress. It is not a failure: the frontend is reconciled again when
+// the SandOpsIngress is created.
+var errWaitingForIngress = errors.New("waiting for the SandOpsIngress of the tenant")
+
+// terminalError is a failure retrying can't fix. It is recorded as a Stalled
+// condition and not retried until the resource changes.
+type terminalError struct {
+	reason string
+	err    error
+}
+
+func (e *terminalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *terminalError) Unwrap() error {
+	return e.err
+}
+
+// terminal marks err as a failure retrying can't fix, reason is the reason of
+// the Stalled condition.
+func terminal(reason string, err error) error {
+	return &terminalError{reason: reason, err: err}
+}
+
+// terminalReason reports whether err is terminal and why. Besides errors
+// marked with terminal, objects the API server rejects as invalid are
+// terminal: they are rendered from the spec and fail the same way every time.
+func terminalReason(err error) (string, bool) {
+	var te *terminalError
+	switch {
+	case err == nil:
+		return "", false
+	case errors.As(err, &te):
+		return te.reason, true
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return "Rejected", true
+	default:
+		return "", false
+	}
+}
+
+// reconcileError turns a failed reconcile into what controller-runtime
+// expects: terminal errors are logged and dropped, anything else is retried
+// with backoff by the workqueue rate limiter.
+func reconcileError(err error) error {
+	if _, ok := terminalReason(err); ok {
+		return reconcile.TerminalError(err)
+	}
+	return err
+}
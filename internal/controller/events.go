@@ -0,0 +1,88 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"sandtech.io/sand-ops/internal/pipeline"
+)
+
+// Reasons of the Events recorded on SandOpsIngress and FrontendDeploy objects.
+// Tenants alert on them, so a reason is never renamed or reused for something
+// else. The README lists them.
+const (
+	// EventReasonCreated (Normal): the operator created an object.
+	EventReasonCreated = "Created"
+	// EventReasonUpdated (Normal): the operator changed an object to match the spec.
+	EventReasonUpdated = "Updated"
+	// EventReasonDeleted (Normal): the operator deleted an object it no longer needs.
+	EventReasonDeleted = "Deleted"
+	// EventReasonApplyFailed (Warning): an object couldn't be created or updated.
+	EventReasonApplyFailed = "ApplyFailed"
+	// EventReasonDeleteFailed (Warning): an object couldn't be deleted.
+	EventReasonDeleteFailed = "DeleteFailed"
+	// EventReasonInvalidSpec (Warning): the spec can't be turned into objects.
+	EventReasonInvalidSpec = "InvalidSpec"
+	// EventReasonDriftRepaired (Normal): changes made to an object outside the
+	// operator were reverted.
+	EventReasonDriftRepaired = "DriftRepaired"
+	// EventReasonDriftDetected (Warning): an object was changed outside the
+	// operator and left alone because of driftPolicy ReportOnly.
+	EventReasonDriftDetected = "DriftDetected"
+	// EventReasonHibernated (Normal): the frontend or tenant was scaled to zero.
+	EventReasonHibernated = "Hibernated"
+	// EventReasonResumed (Normal): a hibernated frontend or tenant was scaled
+	// back up.
+	EventReasonResumed = "Resumed"
+	// EventReasonIdled (Normal): a frontend with an idle policy was scaled to
+	// zero for lack of traffic.
+	EventReasonIdled = "Idled"
+	// EventReasonWoken (Normal): a request woke up an idle frontend.
+	EventReasonWoken = "Woken"
+	// EventReasonExpired (Normal): a preview reached its expiry and was
+	// deleted.
+	EventReasonExpired = "Expired"
+	// EventReasonRolledBack (Normal): the spec of a frontend was restored from
+	// a revision, or the components of an application were rolled back.
+	EventReasonRolledBack = "RolledBack"
+	// EventReasonRollbackFailed (Warning): spec.rollbackTo named a revision
+	// that doesn't exist.
+	EventReasonRollbackFailed = "RollbackFailed"
+	// EventReasonSnippetsDisabled (Warning): a tenant enabled snippets but the
+	// operator doesn't serve the webhook that checks them.
+	EventReasonSnippetsDisabled = "SnippetsDisabled"
+)
+
+// recordApplied records the Event of an object that was applied. Unchanged
+// objects are not worth an Event.
+func recordApplied(recorder record.EventRecorder, target runtime.Object, action pipeline.Action, object string) {
+	switch action {
+	case pipeline.ActionCreated:
+		recorder.Eventf(target, corev1.EventTypeNormal, EventReasonCreated, "created %s", object)
+	case pipeline.ActionUpdated:
+		recorder.Eventf(target, corev1.EventTypeNormal, EventReasonUpdated, "updated %s", object)
+	}
+}
+
+func recordDeleted(recorder record.EventRecorder, target runtime.Object, object string) {
+	recorder.Eventf(target, corev1.EventTypeNormal, EventReasonDeleted, "deleted %s", object)
+}
+
+func recordFailed(recorder record.EventRecorder, target runtime.Object, reason string, err error) {
+	recorder.Event(target, corev1.EventTypeWarning, reason, err.Error())
+}
+
+// recordResults records the Events of the results of a Graph.
+func recordResults(recorder record.EventRecorder, target runtime.Object, results []pipeline.Result, failedReason string) {
+	for _, result := range results {
+		switch result.Action {
+		case pipeline.ActionCreated, pipeline.ActionUpdated:
+			recordApplied(recorder, target, result.Action, result.Object)
+		case pipeline.ActionDeleted:
+			recordDeleted(recorder, target, result.Object)
+		case pipeline.ActionFailed:
+			recordFailed(recorder, target, failedReason, result.Err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// applyFrontendObject server-side applies obj, which only carries the fields
+// the operator owns, and records the Event on target. Like the other reconcile
+// steps it returns errUnchanged when the object was already up to date.
+func (r FrontendDeployReconciler) applyFrontendObject(ctx context.Context, target runtime.Object, obj client.Object) error {
+	action, err := pipeline.ServerSideApply(ctx, r.Client, obj)
+	if err != nil {
+		return err
+	}
+	recordApplied(r.Recorder, target, action, pipeline.ObjectName(r.Client, obj))
+	if action == pipeline.ActionUnchanged {
+		return errUnchanged
+	}
+	return nil
+}
+
+func frontendOwnerReferences(frontendPod *controllerapi.FrontendDeploy) []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		{
+			APIVersion: controllerapi.GroupVersion.String(),
+			Kind:       "FrontendDeploy",
+			Name:       frontendPod.Name,
+			UID:        frontendPod.UID,
+			Controller: utils.DataTypePointerRef(true),
+		},
+	}
+}
+
+// deleteFrontendObject deletes obj and records the Event on target. It
+// returns errUnchanged when obj was already gone.
+func (r FrontendDeployReconciler) deleteFrontendObject(ctx context.Context, target runtime.Object, obj client.Object) error {
+	err := r.Delete(ctx, obj)
+	if errors.IsNotFound(err) {
+		return errUnchanged
+	}
+	if err != nil {
+		return err
+	}
+	recordDeleted(r.Recorder, target, pipeline.ObjectName(r.Client, obj))
+	return nil
+}
@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+// extraResource is an entry of spec.extraResources ready to be applied.
+type extraResource struct {
+	object  *unstructured.Unstructured
+	mapping *meta.RESTMapping
+	// current is the live object, nil when it doesn't exist yet
+	current *unstructured.Unstructured
+}
+
+// reconcileExtraResources applies spec.extraResources through the dynamic
+// client and deletes the ones applied before that were removed from the list.
+// Every entry is checked before any is applied, so a list holding a kind that
+// isn't installed, or an object the frontend doesn't control, changes nothing. It returns the applied objects, which are
+// kept in the status to know what to delete later.
+func (r FrontendDeployReconciler) reconcileExtraResources(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, l logr.Logger) ([]controllerapi.ExtraResourceReference, error) {
+	if len(frontendPod.Spec.ExtraResources) == 0 && len(frontendPod.Status.ExtraResources) == 0 {
+		return nil, errUnchanged
+	}
+	if r.DynamicClientSet == nil {
+		return nil, fmt.Errorf("no dynamic client to apply extra resources with")
+	}
+	l.Info("reconcilling frontend extra resources")
+
+	extras := make([]extraResource, 0, len(frontendPod.Spec.ExtraResources))
+	desired := make([]controllerapi.ExtraResourceReference, 0, len(frontendPod.Spec.ExtraResources))
+	for i, raw := range frontendPod.Spec.ExtraResources {
+		extra, err := r.extraResource(ctx, frontendPod, raw)
+		if err != nil {
+			return nil, fmt.Errorf("extraResources[%d]: %w", i, err)
+		}
+		ref := extraResourceReference(extra.object)
+		if slices.Contains(desired, ref) {
+			return nil, terminal("InvalidExtraResource", fmt.Errorf("extraResources[%d]: %s %s is listed twice", i, ref.Kind, ref.Name))
+		}
+		extras = append(extras, extra)
+		desired = append(desired, ref)
+	}
+
+	changed := false
+	applied := make([]controllerapi.ExtraResourceReference, 0, len(extras))
+	for _, extra := range extras {
+		action, err := r.applyExtraResource(ctx, extra)
+		if err != nil {
+			// remember what was applied so far, so it is deleted once removed
+			// from the spec even if this apply keeps failing
+			if statusErr := r.setExtraResources(ctx, frontendPod, mergeExtraResources(frontendPod.Status.ExtraResources, applied)); statusErr != nil {
+				l.Error(statusErr, "failed to record applied extra resources")
+			}
+			return nil, err
+		}
+		recordApplied(r.Recorder, frontendPod, action, pipeline.ObjectName(r.Client, extra.object))
+		changed = changed || action != pipeline.ActionUnchanged
+		applied = append(applied, extraResourceReference(extra.object))
+	}
+
+	pruned, err := r.pruneExtraResources(ctx, frontendPod, desired)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.setExtraResources(ctx, frontendPod, desired); err != nil {
+		return nil, err
+	}
+	if !changed && !pruned {
+		return desired, errUnchanged
+	}
+	return desired, nil
+}
+
+// extraResource decodes an entry of spec.extraResources and fills in the
+// namespace, labels and owner of the frontend. Entries the operator can't
+// apply, like kinds that aren't installed, cluster scoped ones, objects the
+// operator generates itself or that the frontend doesn't control, are
+// terminal errors.
+func (r FrontendDeployReconciler) extraResource(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, raw runtime.RawExtension) (extraResource, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(raw.Raw); err != nil {
+		return extraResource{}, terminal("InvalidExtraResource", err)
+	}
+	if obj.GetName() == "" {
+		return extraResource{}, terminal("InvalidExtraResource", fmt.Errorf("%s has no name", obj.GetKind()))
+	}
+	if namespace := obj.GetNamespace(); namespace != "" && namespace != frontendPod.Namespace {
+		return extraResource{}, terminal("InvalidExtraResource", fmt.Errorf("%s %s must be in namespace %s, not %s", obj.GetKind(), obj.GetName(), frontendPod.Namespace, namespace))
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := r.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return extraResource{}, terminal("UnknownKind", fmt.Errorf("%s is not installed in the cluster", gvk))
+	}
+	if err != nil {
+		return extraResource{}, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return extraResource{}, terminal("ClusterScopedKind", fmt.Errorf("%s is cluster scoped, extra resources must be namespaced", gvk.Kind))
+	}
+	if reservedExtraResource(frontendPod, obj) {
+		return extraResource{}, terminal("InvalidExtraResource", fmt.Errorf("%s %s is generated by the operator", gvk.Kind, obj.GetName()))
+	}
+
+	// applying with Force would take over the fields another controller, or
+	// another frontend, manages on the object
+	current, err := r.DynamicClientSet.Resource(mapping.Resource).Namespace(frontendPod.Namespace).Get(ctx, obj.GetName(), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		current = nil
+	case err != nil:
+		return extraResource{}, err
+	case !metav1.IsControlledBy(current, frontendPod):
+		return extraResource{}, terminal("InvalidExtraResource", fmt.Errorf("%s %s already exists and isn't controlled by the frontend", gvk.Kind, obj.GetName()))
+	}
+
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[utils.FRONTEND_NAME_LABEL] = frontendPod.Name
+	obj.SetLabels(labels)
+	obj.SetNamespace(frontendPod.Namespace)
+	obj.SetOwnerReferences(frontendOwnerReferences(frontendPod))
+	return extraResource{object: obj, mapping: mapping, current: current}, nil
+}
+
+// reservedExtraResource reports whether the operator generates an object of
+// the kind and name of obj in the namespace of the frontend. These are the
+// objects of the tenant ingress controller and of the frontends, which the
+// ownership check doesn't catch before they are created, nor when they
+// belong to the frontend itself.
+func reservedExtraResource(frontendPod *controllerapi.FrontendDeploy, obj *unstructured.Unstructured) bool {
+	name := obj.GetName()
+	tenant := utils.IngressNamespacedName(frontendPod.Namespace).Name
+	if slices.Contains([]string{
+		utils.INGRESS_NGINX,
+		utils.INGRESS_NGINX_ADMISSION,
+		utils.INGRESS_NGINX_CONTROLLER,
+		utils.INGRESS_NGINX_CONTROLLER_ADMISSION,
+		utils.ERROR_PAGES,
+		utils.ERROR_BACKEND,
+		utils.MAINTENANCE_BACKEND,
+		utils.ACTIVATOR,
+		utils.TCPServicesConfigMapName(tenant),
+		utils.UDPServicesConfigMapName(tenant),
+	}, name) {
+		return true
+	}
+
+	switch obj.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Group: appsv1.GroupName, Kind: "Deployment"}:
+		return name == frontendPod.Name
+	case schema.GroupKind{Group: appsv1.GroupName, Kind: "ControllerRevision"}:
+		return strings.HasPrefix(name, frontendPod.Name+"-")
+	case schema.GroupKind{Group: corev1.GroupName, Kind: "Service"}:
+		return strings.HasSuffix(name, utils.FrontendSVCSuffixedString(""))
+	case schema.GroupKind{Group: corev1.GroupName, Kind: "ConfigMap"}:
+		return strings.HasSuffix(name, utils.FrontendHeadersSuffixedString(""))
+	case schema.GroupKind{Group: networkingv1.GroupName, Kind: "Ingress"}:
+		return strings.HasSuffix(name, utils.FrontendIngressSuffixedString("")) || name == utils.SharedIngressName(frontendPod.Namespace)
+	}
+	return false
+}
+
+// applyExtraResource server-side applies an extra resource and reports what
+// happened to it.
+func (r FrontendDeployReconciler) applyExtraResource(ctx context.Context, extra extraResource) (pipeline.Action, error) {
+	resource := r.DynamicClientSet.Resource(extra.mapping.Resource).Namespace(extra.object.GetNamespace())
+	applied, err := resource.Apply(ctx, extra.object.GetName(), extra.object, metav1.ApplyOptions{FieldManager: pipeline.FieldManager, Force: true})
+	if err != nil {
+		return pipeline.ActionFailed, err
+	}
+
+	switch {
+	case extra.current == nil:
+		return pipeline.ActionCreated, nil
+	case applied.GetResourceVersion() != extra.current.GetResourceVersion():
+		return pipeline.ActionUpdated, nil
+	default:
+		return pipeline.ActionUnchanged, nil
+	}
+}
+
+// pruneExtraResources deletes the extra resources in the status that are no
+// longer desired. Objects another controller took over are left alone, and
+// so are kinds that were uninstalled, which took their objects with them.
+func (r FrontendDeployReconciler) pruneExtraResources(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, desired []controllerapi.ExtraResourceReference) (bool, error) {
+	pruned := false
+	for _, ref := range frontendPod.Status.ExtraResources {
+		if slices.Contains(desired, ref) {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			return pruned, err
+		}
+		mapping, err := r.RESTMapper().RESTMapping(gv.WithKind(ref.Kind).GroupKind(), gv.Version)
+		if meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return pruned, err
+		}
+
+		resource := r.DynamicClientSet.Resource(mapping.Resource).Namespace(frontendPod.Namespace)
+		current, err := resource.Get(ctx, ref.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return pruned, err
+		}
+		if !metav1.IsControlledBy(current, frontendPod) {
+			continue
+		}
+		uid := current.GetUID()
+		err = resource.Delete(ctx, ref.Name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			recordFailed(r.Recorder, frontendPod, EventReasonDeleteFailed, err)
+			return pruned, err
+		}
+		recordDeleted(r.Recorder, frontendPod, pipeline.ObjectName(r.Client, current))
+		pruned = true
+	}
+	return pruned, nil
+}
+
+// setExtraResources records the applied extra resources in the status,
+// writing only when they changed.
+func (r FrontendDeployReconciler) setExtraResources(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, refs []controllerapi.ExtraResourceReference) error {
+	if slices.Equal(frontendPod.Status.ExtraResources, refs) {
+		return nil
+	}
+	if len(refs) == 0 {
+		refs = nil
+	}
+	frontendPod.Status.ExtraResources = refs
+	return r.Status().Update(ctx, frontendPod)
+}
+
+func extraResourceReference(obj *unstructured.Unstructured) controllerapi.ExtraResourceReference {
+	return controllerapi.ExtraResourceReference{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Name:       obj.GetName(),
+	}
+}
+
+// mergeExtraResources returns previous followed by the entries of applied it
+// doesn't hold yet.
+func mergeExtraResources(previous, applied []controllerapi.ExtraResourceReference) []controllerapi.ExtraResourceReference {
+	merged := slices.Clone(previous)
+	for _, ref := range applied {
+		if !slices.Contains(merged, ref) {
+			merged = append(merged, ref)
+		}
+	}
+	return merged
+}
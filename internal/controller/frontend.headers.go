@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+// reconcileFrontendHeaders keeps the ConfigMap read by the ingress-nginx
+// custom-headers annotation in line with spec.responseHeaders.
+func (r FrontendDeployReconciler) reconcileFrontendHeaders(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, l logr.Logger) (corev1.ConfigMap, error) {
+	l.Info("reconciling frontend response headers")
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            utils.FrontendHeadersSuffixedString(frontendPod.Name),
+			Namespace:       frontendPod.Namespace,
+			OwnerReferences: frontendOwnerReferences(frontendPod),
+		},
+	}
+	if len(frontendPod.Spec.ResponseHeaders) == 0 {
+		return *configMap, r.deleteFrontendObject(ctx, frontendPod, configMap)
+	}
+
+	configMap.Data = frontendPod.Spec.ResponseHeaders
+	return *configMap, r.applyFrontendObject(ctx, frontendPod, configMap)
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+// updateFrontendHibernation works out whether the frontend hibernates at now,
+// on its own or with its tenant, and records it in the Hibernated condition
+// the deployment step scales by. It returns when the outcome may change next.
+func (r FrontendDeployReconciler) updateFrontendHibernation(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, now time.Time) (time.Time, error) {
+	// the schedule was validated with the rest of the spec
+	hibernation, err := utils.EvaluateHibernation(frontendPod.Spec.Hibernate, frontendPod.Spec.HibernationSchedule, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ingressResource, err := utils.GetIngress(frontendPod.Namespace, ctx, r.Client)
+	if err != nil && !errors.IsNotFound(err) {
+		return time.Time{}, err
+	}
+	if err == nil {
+		// an invalid tenant schedule is reported on the SandOpsIngress
+		tenant, err := utils.EvaluateHibernation(ingressResource.Spec.Hibernate, ingressResource.Spec.HibernationSchedule, now)
+		if err == nil {
+			if tenant.Hibernated && !hibernation.Hibernated {
+				hibernation.Hibernated, hibernation.Reason = true, utils.HibernatedByTenant
+			}
+			hibernation.Next = earliest(hibernation.Next, tenant.Next)
+		}
+	}
+
+	wasHibernated := frontendHibernated(frontendPod)
+	status, message := metav1.ConditionFalse, "the frontend runs its replicas"
+	if hibernation.Hibernated {
+		status, message = metav1.ConditionTrue, "the frontend is scaled to zero"
+	}
+	if err := r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionHibernated, status, hibernation.Reason, message); err != nil {
+		return time.Time{}, err
+	}
+	switch {
+	case hibernation.Hibernated && !wasHibernated:
+		r.Recorder.Eventf(frontendPod, corev1.EventTypeNormal, EventReasonHibernated, "scaled to zero: %s", hibernation.Reason)
+	case !hibernation.Hibernated && wasHibernated:
+		r.Recorder.Event(frontendPod, corev1.EventTypeNormal, EventReasonResumed, "scaled back up")
+	}
+	return hibernation.Next, nil
+}
+
+// frontendHibernated reports whether the frontend was last found hibernated.
+func frontendHibernated(frontendPod *controllerapi.FrontendDeploy) bool {
+	return meta.IsStatusConditionTrue(frontendPod.Status.Conditions, controllerapi.FrontendConditionHibernated)
+}
+
+// earliest returns the earlier of two instants, ignoring zero ones.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// requeueAt turns the next hibernation change or idle check into a
+// RequeueAfter, zero when nothing is scheduled.
+func requeueAt(next time.Time, now time.Time) time.Duration {
+	if next.IsZero() {
+		return 0
+	}
+	return next.Sub(now)
+}
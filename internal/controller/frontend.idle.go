@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+// idleCheckPeriod is how often the traffic of an awake frontend with an idle
+// policy is read.
+const idleCheckPeriod = time.Minute
+
+// updateFrontendIdle reads the traffic of a frontend with an idle policy and
+// records in the Idle condition whether it went without requests for longer
+// than the policy allows. The deployment step scales an idle frontend to zero
+// and the activator records the request that wakes it up in status.idle. It
+// returns when the traffic should be read again.
+func (r FrontendDeployReconciler) updateFrontendIdle(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, now time.Time, l logr.Logger) (time.Time, error) {
+	policy := frontendPod.Spec.IdlePolicy
+	if policy == nil {
+		if frontendPod.Status.Idle != nil {
+			frontendPod.Status.Idle = nil
+			if err := r.Status().Update(ctx, frontendPod); err != nil {
+				return time.Time{}, err
+			}
+		}
+		return time.Time{}, r.removeFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionIdle)
+	}
+	if r.RequestCounter == nil {
+		return time.Time{}, fmt.Errorf("no request counter configured for the idle policy of %s/%s", frontendPod.Namespace, frontendPod.Name)
+	}
+
+	requests, err := r.RequestCounter.Requests(ctx, frontendPod.Namespace, utils.FrontendSVCSuffixedString(frontendPod.Name))
+	if err != nil {
+		// without metrics the frontend stays as it is rather than being scaled down blindly
+		l.Error(err, "failed to read frontend traffic")
+		return now.Add(idleCheckPeriod), nil
+	}
+
+	idle := frontendPod.Status.Idle.DeepCopy()
+	switch {
+	case idle == nil:
+		// the frontend gets its full idle time once the policy is set
+		idle = &controllerapi.IdleStatus{Requests: requests, LastRequestTime: metav1.NewTime(now)}
+	case requests != idle.Requests:
+		// the count restarts with the ingress controller pods, any change but a drop to zero is traffic
+		if requests > 0 {
+			idle.LastRequestTime = metav1.NewTime(now)
+		}
+		idle.Requests = requests
+	}
+	if !equality.Semantic.DeepEqual(idle, frontendPod.Status.Idle) {
+		frontendPod.Status.Idle = idle
+		if err := r.Status().Update(ctx, frontendPod); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	wasIdle := frontendIdle(frontendPod)
+	idleAt := idle.LastRequestTime.Add(time.Duration(policy.IdleMinutes) * time.Minute)
+	if !now.Before(idleAt) {
+		message := fmt.Sprintf("no requests since %s, the activator wakes the frontend up", idle.LastRequestTime.UTC().Format(time.RFC3339))
+		if err := r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionIdle, metav1.ConditionTrue, "NoTraffic", message); err != nil {
+			return time.Time{}, err
+		}
+		if !wasIdle {
+			r.Recorder.Eventf(frontendPod, corev1.EventTypeNormal, EventReasonIdled, "scaled to zero after %d minutes without requests", policy.IdleMinutes)
+		}
+		// requests to an idle frontend go to the activator, which writes the status
+		return time.Time{}, nil
+	}
+
+	if err := r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionIdle, metav1.ConditionFalse, "Active", fmt.Sprintf("last request at %s", idle.LastRequestTime.UTC().Format(time.RFC3339))); err != nil {
+		return time.Time{}, err
+	}
+	if wasIdle {
+		r.Recorder.Event(frontendPod, corev1.EventTypeNormal, EventReasonWoken, "woken up by a request")
+	}
+	return earliest(idleAt, now.Add(idleCheckPeriod)), nil
+}
+
+// frontendIdle reports whether the frontend was last found idle.
+func frontendIdle(frontendPod *controllerapi.FrontendDeploy) bool {
+	return meta.IsStatusConditionTrue(frontendPod.Status.Conditions, controllerapi.FrontendConditionIdle)
+}
@@ -0,0 +1,343 @@
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// hasDedicatedIngress reports whether a frontend needs an Ingress of its own.
+// ingress-nginx applies annotations per Ingress object, so a frontend with its
+// own policy can't be a path on the shared tenant Ingress. Neither can a
+// frontend with a host, the rule of the shared Ingress has none.
+func hasDedicatedIngress(frontendPod *controllerapi.FrontendDeploy) bool {
+	return frontendPod.Spec.Host != "" ||
+		frontendPod.Spec.RateLimit != nil ||
+		frontendPod.Spec.CORS != nil ||
+		len(frontendPod.Spec.ResponseHeaders) > 0
+}
+
+func frontendIngressPath(frontendPod *controllerapi.FrontendDeploy) string {
+	if frontendPod.Spec.IsHost {
+		return "/?(.*)"
+	}
+	return "/" + frontendPod.Name + "/?(.*)"
+}
+
+// frontendRoute is the backend the path of a frontend leads to.
+type frontendRoute int
+
+const (
+	// routeFrontend sends requests to the Service of the frontend.
+	routeFrontend frontendRoute = iota
+	// routeMaintenance sends requests to the maintenance backend of the tenant.
+	routeMaintenance
+	// routeActivator sends requests to the activator, which wakes up an idle
+	// frontend and holds them until it has a replica.
+	routeActivator
+)
+
+// frontendIngressHTTPPath renders the path of a frontend leading to route.
+func frontendIngressHTTPPath(frontendPod *controllerapi.FrontendDeploy, route frontendRoute) networkingv1.HTTPIngressPath {
+	pathType := networkingv1.PathTypeImplementationSpecific
+	backend := &networkingv1.IngressServiceBackend{
+		Name: utils.FrontendSVCSuffixedString(frontendPod.Name),
+		Port: networkingv1.ServiceBackendPort{
+			Number: frontendPod.Spec.Port,
+		},
+	}
+	switch route {
+	case routeMaintenance:
+		backend = &networkingv1.IngressServiceBackend{
+			Name: utils.MAINTENANCE_BACKEND,
+			Port: networkingv1.ServiceBackendPort{
+				Number: 80,
+			},
+		}
+	case routeActivator:
+		backend = &networkingv1.IngressServiceBackend{
+			Name: utils.ACTIVATOR,
+			Port: networkingv1.ServiceBackendPort{
+				Number: 80,
+			},
+		}
+	}
+	return networkingv1.HTTPIngressPath{
+		Path:     frontendIngressPath(frontendPod),
+		PathType: &pathType,
+		Backend:  networkingv1.IngressBackend{Service: backend},
+	}
+}
+
+// wakesOnRequest reports whether requests to a frontend go to the activator:
+// it has an idle policy and no available replica, because it is idle or still
+// waking up. A hibernated frontend isn't woken by requests.
+func wakesOnRequest(frontendPod *controllerapi.FrontendDeploy, available bool) bool {
+	return frontendPod.Spec.IdlePolicy != nil && !available && !frontendHibernated(frontendPod)
+}
+
+func baseIngressAnnotations() map[string]string {
+	return map[string]string{
+		"nginx.ingress.kubernetes.io/use-regex":       "true",
+		"nginx.ingress.kubernetes.io/rewrite-target":  "/$1",
+		"nginx.ingress.kubernetes.io/proxy-body-size": "8m",
+	}
+}
+
+// frontendIngressAnnotations renders the annotations of a dedicated frontend
+// Ingress. Requests to the activator carry the frontend in their Host.
+func frontendIngressAnnotations(frontendPod *controllerapi.FrontendDeploy, ingressResource *controllerapi.SandOpsIngress, route frontendRoute) map[string]string {
+	annotations := baseIngressAnnotations()
+	for key, value := range utils.RateLimitAnnotations(utils.TenantRateLimit(ingressResource), frontendPod.Spec.RateLimit) {
+		annotations[key] = value
+	}
+	for key, value := range utils.CORSAnnotations(frontendPod.Spec.CORS) {
+		annotations[key] = value
+	}
+	if len(frontendPod.Spec.ResponseHeaders) > 0 {
+		annotations[utils.CUSTOM_HEADERS_ANNOTATION] = frontendPod.Namespace + "/" + utils.FrontendHeadersSuffixedString(frontendPod.Name)
+	}
+	if route == routeActivator {
+		annotations[utils.UPSTREAM_VHOST_ANNOTATION] = utils.ActivatorVirtualHost(frontendPod)
+	}
+	return annotations
+}
+
+func (r FrontendDeployReconciler) reconcileFrontendIngress(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, l logr.Logger) (networkingv1.Ingress, error) {
+	l.Info("reconcilling frontend ingress")
+	ingressResource, err := utils.GetIngress(frontendPod.Namespace, ctx, r.Client)
+	if errors.IsNotFound(err) {
+		return networkingv1.Ingress{}, fmt.Errorf("%w: SandOpsIngress %s not found", errWaitingForIngress, utils.IngressNamespacedName(frontendPod.Namespace))
+	}
+	if err != nil {
+		return networkingv1.Ingress{}, err
+	}
+	if !ingressResource.DeletionTimestamp.IsZero() {
+		return networkingv1.Ingress{}, fmt.Errorf("%w: SandOpsIngress %s is being deleted", errWaitingForIngress, utils.IngressNamespacedName(frontendPod.Namespace))
+	}
+
+	available, err := r.frontendAvailable(ctx, frontendPod)
+	if err != nil {
+		return networkingv1.Ingress{}, err
+	}
+	route := routeFrontend
+	switch {
+	case utils.InMaintenance(frontendPod, ingressResource):
+		route = routeMaintenance
+	case wakesOnRequest(frontendPod, available):
+		route = routeActivator
+	}
+
+	// the activator needs the frontend in the Host, which only a dedicated Ingress can set
+	if hasDedicatedIngress(frontendPod) || route == routeActivator {
+		if _, err := r.reconcileSharedFrontendIngress(ctx, frontendPod.Namespace, ingressResource); err != nil && !goerrors.Is(err, errUnchanged) {
+			return networkingv1.Ingress{}, err
+		}
+		if !available && route == routeFrontend {
+			withdrawn := networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: utils.FrontendIngressSuffixedString(frontendPod.Name), Namespace: frontendPod.Namespace}}
+			return withdrawn, r.deleteDedicatedFrontendIngress(ctx, frontendPod)
+		}
+		return r.reconcileDedicatedFrontendIngress(ctx, frontendPod, ingressResource, route)
+	}
+
+	if err := r.deleteDedicatedFrontendIngress(ctx, frontendPod); err != nil {
+		return networkingv1.Ingress{}, err
+	}
+	return r.reconcileSharedFrontendIngress(ctx, frontendPod.Namespace, ingressResource)
+}
+
+// reconcileSharedFrontendIngress renders the tenant Ingress with a path for
+// every frontend of the namespace that has no Ingress of its own. Rules and
+// paths are atomic lists under server-side apply, so the Ingress is always
+// applied whole rather than one path at a time.
+func (r FrontendDeployReconciler) reconcileSharedFrontendIngress(ctx context.Context, namespace string, ingressResource *controllerapi.SandOpsIngress) (networkingv1.Ingress, error) {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      utils.SharedIngressName(namespace),
+			Namespace: namespace,
+		},
+	}
+
+	paths, err := r.sharedIngressPaths(ctx, namespace, ingressResource)
+	if err != nil {
+		return *ingress, err
+	}
+	if len(paths) == 0 {
+		// an Ingress rule needs at least one path, the next shared frontend recreates it
+		return *ingress, r.deleteFrontendObject(ctx, ingressResource, ingress)
+	}
+
+	annotations := baseIngressAnnotations()
+	for key, value := range utils.RateLimitAnnotations(utils.TenantRateLimit(ingressResource), nil) {
+		annotations[key] = value
+	}
+
+	ingress.Annotations = annotations
+	setOwnedMeta(ingress, ingressResource, nil)
+	ingress.Spec = networkingv1.IngressSpec{
+		IngressClassName: utils.DataTypePointerRef("nginx-" + namespace),
+		Rules: []networkingv1.IngressRule{
+			{
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: paths,
+					},
+				},
+			},
+		},
+	}
+	return *ingress, r.applyFrontendObject(ctx, ingressResource, ingress)
+}
+
+// sharedIngressPaths lists the paths of the available frontends sharing the
+// tenant Ingress, ordered by frontend name. When two frontends claim the same
+// path the first one keeps it. Frontends in maintenance are listed whether
+// available or not, their path leads to the maintenance page. Idle frontends
+// have no available replica and are routed by their own Ingress.
+func (r FrontendDeployReconciler) sharedIngressPaths(ctx context.Context, namespace string, ingressResource *controllerapi.SandOpsIngress) ([]networkingv1.HTTPIngressPath, error) {
+	frontends := &controllerapi.FrontendDeployList{}
+	if err := r.List(ctx, frontends, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	sort.Slice(frontends.Items, func(i, j int) bool {
+		return frontends.Items[i].Name < frontends.Items[j].Name
+	})
+
+	paths := []networkingv1.HTTPIngressPath{}
+	for i := range frontends.Items {
+		frontend := &frontends.Items[i]
+		if !frontend.DeletionTimestamp.IsZero() || hasDedicatedIngress(frontend) {
+			continue
+		}
+		if exists, _, _ := utils.IngressPathExists(paths, frontendIngressPath(frontend)); exists {
+			continue
+		}
+		if utils.InMaintenance(frontend, ingressResource) {
+			paths = append(paths, frontendIngressHTTPPath(frontend, routeMaintenance))
+			continue
+		}
+		available, err := r.frontendAvailable(ctx, frontend)
+		if err != nil {
+			return nil, err
+		}
+		if !available {
+			continue
+		}
+		paths = append(paths, frontendIngressHTTPPath(frontend, routeFrontend))
+	}
+	return paths, nil
+}
+
+// frontendAvailable reports whether the Deployment of a frontend has at least
+// one available replica. A frontend is only routed while it has one, so users
+// don't get 502s while the first pods pull their image or after all crashed.
+func (r FrontendDeployReconciler) frontendAvailable(ctx context.Context, frontendPod *controllerapi.FrontendDeploy) (bool, error) {
+	deployment := &appsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Name: frontendPod.Name, Namespace: frontendPod.Namespace}, deployment)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return deployment.Status.AvailableReplicas > 0, nil
+}
+
+// ingressPathBackend returns the Service ingress sends the path of the
+// frontend to, empty when ingress has no such path.
+func ingressPathBackend(ingress networkingv1.Ingress, frontendPod *controllerapi.FrontendDeploy) string {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Path == frontendIngressPath(frontendPod) && path.Backend.Service != nil {
+				return path.Backend.Service.Name
+			}
+		}
+	}
+	return ""
+}
+
+// reconcileDedicatedFrontendIngress keeps the Ingress owned by the frontend in
+// line with its spec. Frontend settings override the tenant defaults.
+func (r FrontendDeployReconciler) reconcileDedicatedFrontendIngress(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, ingressResource *controllerapi.SandOpsIngress, route frontendRoute) (networkingv1.Ingress, error) {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            utils.FrontendIngressSuffixedString(frontendPod.Name),
+			Namespace:       frontendPod.Namespace,
+			OwnerReferences: frontendOwnerReferences(frontendPod),
+			Annotations:     frontendIngressAnnotations(frontendPod, ingressResource, route),
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: utils.DataTypePointerRef("nginx-" + frontendPod.Namespace),
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: frontendPod.Spec.Host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								frontendIngressHTTPPath(frontendPod, route),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	return *ingress, r.applyFrontendObject(ctx, frontendPod, ingress)
+}
+
+func (r FrontendDeployReconciler) deleteDedicatedFrontendIngress(ctx context.Context, frontendPod *controllerapi.FrontendDeploy) error {
+	ingress := &networkingv1.Ingress{}
+	err := r.Get(ctx, types.NamespacedName{Name: utils.FrontendIngressSuffixedString(frontendPod.Name), Namespace: frontendPod.Namespace}, ingress)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := r.deleteFrontendObject(ctx, frontendPod, ingress); err != nil && !goerrors.Is(err, errUnchanged) {
+		return err
+	}
+	return nil
+}
+
+// tenantToFrontends maps the SandOpsIngress of a tenant, or its shared
+// Ingress, to every FrontendDeploy of the tenant, so frontends created before
+// the SandOpsIngress get their routes once it appears.
+func (r *FrontendDeployReconciler) tenantToFrontends(ctx context.Context, obj client.Object) []reconcile.Request {
+	switch obj.(type) {
+	case *controllerapi.SandOpsIngress:
+		if utils.IngressNamespacedName(obj.GetNamespace()).Name != obj.GetName() {
+			return nil
+		}
+	case *networkingv1.Ingress:
+		if obj.GetName() != utils.SharedIngressName(obj.GetNamespace()) {
+			return nil
+		}
+	}
+
+	frontends := &controllerapi.FrontendDeployList{}
+	if err := r.List(ctx, frontends, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list the frontends of tenant", "namespace", obj.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(frontends.Items))
+	for i := range frontends.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&frontends.Items[i])})
+	}
+	return requests
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultRevisionHistoryLimit is how many revisions are kept without
+// spec.revisionHistoryLimit.
+const defaultRevisionHistoryLimit = 10
+
+// Revisions of a frontend are ControllerRevisions, the immutable snapshots
+// StatefulSets keep too. Each records a distinct revisionSpec, the revision
+// number moves to the front when an older spec comes back.
+
+// revisionSpec is the part of the spec a revision records: what the frontend
+// runs and how it is routed. Suspend, hibernation, maintenance and the idle
+// policy are switches rather than releases, so rolling back leaves them as
+// they are.
+func revisionSpec(spec controllerapi.FrontendDeploySpec) controllerapi.FrontendDeploySpec {
+	snapshot := *spec.DeepCopy()
+	snapshot.Suspend = false
+	snapshot.Hibernate = false
+	snapshot.HibernationSchedule = nil
+	snapshot.Maintenance = false
+	snapshot.IdlePolicy = nil
+	snapshot.RevisionHistoryLimit = nil
+	snapshot.RollbackTo = nil
+	return snapshot
+}
+
+// restoreRevisionSpec returns spec with the recorded part replaced by
+// snapshot.
+func restoreRevisionSpec(spec, snapshot controllerapi.FrontendDeploySpec) controllerapi.FrontendDeploySpec {
+	restored := *snapshot.DeepCopy()
+	restored.Suspend = spec.Suspend
+	restored.Hibernate = spec.Hibernate
+	restored.HibernationSchedule = spec.HibernationSchedule
+	restored.Maintenance = spec.Maintenance
+	restored.IdlePolicy = spec.IdlePolicy
+	restored.RevisionHistoryLimit = spec.RevisionHistoryLimit
+	return restored
+}
+
+// listFrontendRevisions returns the revisions of a frontend, oldest first.
+func (r FrontendDeployReconciler) listFrontendRevisions(ctx context.Context, frontendPod *controllerapi.FrontendDeploy) ([]appsv1.ControllerRevision, error) {
+	revisions := &appsv1.ControllerRevisionList{}
+	if err := r.List(ctx, revisions, client.InNamespace(frontendPod.Namespace), client.MatchingLabels{utils.FRONTEND_NAME_LABEL: frontendPod.Name}); err != nil {
+		return nil, err
+	}
+	owned := revisions.Items[:0]
+	for _, revision := range revisions.Items {
+		if metav1.IsControlledBy(&revision, frontendPod) {
+			owned = append(owned, revision)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return owned[i].Revision < owned[j].Revision
+	})
+	return owned, nil
+}
+
+// rollbackFrontend restores the spec recorded in spec.rollbackTo and clears
+// the field. The update brings the frontend back for a reconcile of the
+// restored spec.
+func (r FrontendDeployReconciler) rollbackFrontend(ctx context.Context, frontendPod *controllerapi.FrontendDeploy) error {
+	target := *frontendPod.Spec.RollbackTo
+	revisions, err := r.listFrontendRevisions(ctx, frontendPod)
+	if err != nil {
+		return err
+	}
+	var found *appsv1.ControllerRevision
+	for i := range revisions {
+		if revisions[i].Revision == target {
+			found = &revisions[i]
+		}
+	}
+
+	if found == nil {
+		// the frontend keeps running its spec, the field is cleared so a rollback can be retried
+		frontendPod.Spec.RollbackTo = nil
+		if err := r.Update(ctx, frontendPod); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(frontendPod, corev1.EventTypeWarning, EventReasonRollbackFailed, "revision %d not found", target)
+		return nil
+	}
+	snapshot := controllerapi.FrontendDeploySpec{}
+	if err := json.Unmarshal(found.Data.Raw, &snapshot); err != nil {
+		return terminal("InvalidRevision", fmt.Errorf("decoding revision %d: %w", target, err))
+	}
+	frontendPod.Spec = restoreRevisionSpec(frontendPod.Spec, snapshot)
+	if err := r.Update(ctx, frontendPod); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(frontendPod, corev1.EventTypeNormal, EventReasonRolledBack, "rolled back to revision %d", target)
+	return nil
+}
+
+// recordFrontendRevision records the current spec as the newest revision,
+// reusing the revision of an identical older spec, sets status.updateRevision
+// and prunes revisions beyond the history limit.
+func (r FrontendDeployReconciler) recordFrontendRevision(ctx context.Context, frontendPod *controllerapi.FrontendDeploy) error {
+	data, err := json.Marshal(revisionSpec(frontendPod.Spec))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:10]
+
+	revisions, err := r.listFrontendRevisions(ctx, frontendPod)
+	if err != nil {
+		return err
+	}
+	var latest int64
+	var current *appsv1.ControllerRevision
+	for i := range revisions {
+		if revisions[i].Revision > latest {
+			latest = revisions[i].Revision
+		}
+		if revisions[i].Labels[utils.REVISION_HASH_LABEL] == hash {
+			current = &revisions[i]
+		}
+	}
+
+	switch {
+	case current == nil:
+		current = &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      frontendPod.Name + "-" + hash,
+				Namespace: frontendPod.Namespace,
+				Labels: map[string]string{
+					utils.FRONTEND_NAME_LABEL: frontendPod.Name,
+					utils.REVISION_HASH_LABEL: hash,
+				},
+				OwnerReferences: frontendOwnerReferences(frontendPod),
+			},
+			Data:     runtime.RawExtension{Raw: data},
+			Revision: latest + 1,
+		}
+		if err := r.Create(ctx, current); err != nil {
+			return err
+		}
+		revisions = append(revisions, *current)
+	case current.Revision != latest:
+		// an older spec came back, it becomes the newest revision
+		current.Revision = latest + 1
+		if err := r.Update(ctx, current); err != nil {
+			return err
+		}
+		sort.Slice(revisions, func(i, j int) bool {
+			return revisions[i].Revision < revisions[j].Revision
+		})
+	}
+	updateRevision := revisions[len(revisions)-1].Revision
+
+	if frontendPod.Status.UpdateRevision != updateRevision {
+		frontendPod.Status.UpdateRevision = updateRevision
+		if err := r.Status().Update(ctx, frontendPod); err != nil {
+			return err
+		}
+	}
+	return r.pruneFrontendRevisions(ctx, frontendPod, revisions)
+}
+
+// pruneFrontendRevisions deletes the oldest revisions beyond the history
+// limit, keeping the current and the update revision whatever their age.
+func (r FrontendDeployReconciler) pruneFrontendRevisions(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, revisions []appsv1.ControllerRevision) error {
+	limit := defaultRevisionHistoryLimit
+	if frontendPod.Spec.RevisionHistoryLimit != nil {
+		limit = int(*frontendPod.Spec.RevisionHistoryLimit)
+	}
+	excess := len(revisions) - limit
+	for i := 0; i < len(revisions) && excess > 0; i++ {
+		revision := &revisions[i]
+		if revision.Revision == frontendPod.Status.CurrentRevision || revision.Revision == frontendPod.Status.UpdateRevision {
+			continue
+		}
+		if err := r.Delete(ctx, revision); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		excess--
+	}
+	return nil
+}
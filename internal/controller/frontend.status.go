@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/metrics"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// setFrontendCondition records a condition on the FrontendDeploy status and
+// only writes to the API server when the condition actually changed.
+func (r FrontendDeployReconciler) setFrontendCondition(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, conditionType string, status metav1.ConditionStatus, reason, message string) error {
+	changed := meta.SetStatusCondition(&frontendPod.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: frontendPod.Generation,
+	})
+	if !changed {
+		return nil
+	}
+	return r.Status().Update(ctx, frontendPod)
+}
+
+// removeFrontendCondition drops a condition from the FrontendDeploy status,
+// writing only when it was set.
+func (r FrontendDeployReconciler) removeFrontendCondition(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, conditionType string) error {
+	if !meta.RemoveStatusCondition(&frontendPod.Status.Conditions, conditionType) {
+		return nil
+	}
+	return r.Status().Update(ctx, frontendPod)
+}
+
+// updateFrontendReadiness sets the Ready condition from the rollout of the
+// frontend Deployment, and records how long the frontend took to become Ready
+// the first time. A rolled out revision becomes the current one.
+func (r FrontendDeployReconciler) updateFrontendReadiness(ctx context.Context, frontendPod *controllerapi.FrontendDeploy) error {
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKey{Name: frontendPod.Name, Namespace: frontendPod.Namespace}, deployment); err != nil {
+		return err
+	}
+
+	previous := meta.FindStatusCondition(frontendPod.Status.Conditions, controllerapi.FrontendConditionReady)
+	firstReady := previous == nil || previous.Reason == "Deploying"
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	if desired == 0 {
+		reason := "Hibernated"
+		if !frontendHibernated(frontendPod) && frontendIdle(frontendPod) {
+			reason = "Idle"
+		}
+		return r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionReady, metav1.ConditionFalse, reason, "the frontend is scaled to zero")
+	}
+
+	if deploymentRolledOut(deployment, desired) {
+		if frontendPod.Status.CurrentRevision != frontendPod.Status.UpdateRevision {
+			frontendPod.Status.CurrentRevision = frontendPod.Status.UpdateRevision
+			if err := r.Status().Update(ctx, frontendPod); err != nil {
+				return err
+			}
+		}
+		if err := r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionReady, metav1.ConditionTrue, "Available", ""); err != nil {
+			return err
+		}
+		if firstReady {
+			metrics.FrontendTimeToReady.Observe(time.Since(frontendPod.CreationTimestamp.Time).Seconds())
+		}
+		return nil
+	}
+
+	reason := "Unavailable"
+	if firstReady {
+		reason = "Deploying"
+	}
+	message := fmt.Sprintf("%d of %d replicas available", deployment.Status.AvailableReplicas, desired)
+	return r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionReady, metav1.ConditionFalse, reason, message)
+}
+
+// updateFrontendRouting sets the Routed condition from the Ingress the
+// ingress step rendered for the frontend.
+func (r FrontendDeployReconciler) updateFrontendRouting(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, ingress networkingv1.Ingress) error {
+	switch ingressPathBackend(ingress, frontendPod) {
+	case utils.FrontendSVCSuffixedString(frontendPod.Name):
+		return r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionRouted, metav1.ConditionTrue, "Published", fmt.Sprintf("served by Ingress %s", ingress.Name))
+	case utils.MAINTENANCE_BACKEND:
+		return r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionRouted, metav1.ConditionTrue, "Maintenance", fmt.Sprintf("Ingress %s serves the maintenance page", ingress.Name))
+	case utils.ACTIVATOR:
+		return r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionRouted, metav1.ConditionTrue, "WakeOnRequest", fmt.Sprintf("Ingress %s holds requests in the activator until the frontend has a replica", ingress.Name))
+	case "":
+		available, err := r.frontendAvailable(ctx, frontendPod)
+		if err != nil {
+			return err
+		}
+		if frontendHibernated(frontendPod) {
+			return r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionRouted, metav1.ConditionFalse, "Hibernated", "the path is published again once the frontend wakes up")
+		}
+		if !available {
+			return r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionRouted, metav1.ConditionFalse, "NoAvailableReplicas", "the path is published once a replica is available")
+		}
+	}
+	return r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionRouted, metav1.ConditionFalse, "PathTaken", fmt.Sprintf("path %s is served by another frontend", frontendIngressPath(frontendPod)))
+}
+
+// deploymentRolledOut reports whether every replica of deployment runs the
+// current template and is available.
+func deploymentRolledOut(deployment *appsv1.Deployment, desired int32) bool {
+	status := deployment.Status
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == desired &&
+		status.AvailableReplicas == desired &&
+		status.Replicas == desired
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("FrontendDeploy extra resources", func() {
+	var (
+		ctx           context.Context
+		c             client.Client
+		dynamicClient *dynamicfake.FakeDynamicClient
+		reconciler    *FrontendDeployReconciler
+		frontend      *aasdevv1.FrontendDeploy
+	)
+
+	serviceMonitors := schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "servicemonitors"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		// the kinds installed in the cluster: the built-in ones, ServiceMonitor
+		// and a cluster scoped custom resource
+		mapper := meta.NewDefaultRESTMapper(nil)
+		for gvk := range testScheme.AllKnownTypes() {
+			mapper.Add(gvk, meta.RESTScopeNamespace)
+		}
+		mapper.Add(serviceMonitors.GroupVersion().WithKind("ServiceMonitor"), meta.RESTScopeNamespace)
+		mapper.Add(schema.GroupVersionKind{Group: "example.io", Version: "v1", Kind: "Cluster"}, meta.RESTScopeRoot)
+
+		frontend = &aasdevv1.FrontendDeploy{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns", UID: "web-uid"},
+			Spec:       aasdevv1.FrontendDeploySpec{ImageName: "nginx", Port: 80},
+		}
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithRESTMapper(mapper).
+			WithObjects(frontend).
+			WithStatusSubresource(&aasdevv1.FrontendDeploy{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+
+		dynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			serviceMonitors: "ServiceMonitorList",
+		})
+		// the fake can't merge apply patches into unstructured objects, stand in
+		// for the API server by creating or replacing the applied object
+		dynamicClient.PrependReactor("patch", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			patch := action.(clienttesting.PatchAction)
+			if patch.GetPatchType() != types.ApplyPatchType {
+				return false, nil, nil
+			}
+			obj := &unstructured.Unstructured{}
+			if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+				return true, nil, err
+			}
+			tracker := dynamicClient.Tracker()
+			if _, err := tracker.Get(patch.GetResource(), patch.GetNamespace(), patch.GetName()); errors.IsNotFound(err) {
+				return true, obj, tracker.Create(patch.GetResource(), obj, patch.GetNamespace())
+			}
+			return true, obj, tracker.Update(patch.GetResource(), obj, patch.GetNamespace())
+		})
+
+		reconciler = &FrontendDeployReconciler{
+			Client:      c,
+			Scheme:      testScheme,
+			KubeClients: KubeClients{DynamicClientSet: dynamicClient},
+			Recorder:    record.NewFakeRecorder(100),
+		}
+	})
+
+	setExtraResources := func(manifests ...string) {
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(frontend), frontend)).To(Succeed())
+		frontend.Spec.ExtraResources = nil
+		for _, manifest := range manifests {
+			frontend.Spec.ExtraResources = append(frontend.Spec.ExtraResources, runtime.RawExtension{Raw: []byte(manifest)})
+		}
+		Expect(c.Update(ctx, frontend)).To(Succeed())
+	}
+	serviceMonitor := func(name string) string {
+		return `{"apiVersion":"monitoring.coreos.com/v1","kind":"ServiceMonitor","metadata":{"name":"` + name + `"},"spec":{"endpoints":[{"port":"http"}]}}`
+	}
+	getServiceMonitor := func(name string) (*unstructured.Unstructured, error) {
+		return dynamicClient.Resource(serviceMonitors).Namespace("tenant-ns").Get(ctx, name, metav1.GetOptions{})
+	}
+
+	It("applies the extra resources owned by the frontend", func() {
+		setExtraResources(serviceMonitor("web-metrics"))
+
+		_, err := reconciler.reconcileExtraResources(ctx, frontend, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		applied, err := getServiceMonitor("web-metrics")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied.GetLabels()).To(HaveKeyWithValue(utils.FRONTEND_NAME_LABEL, "web"))
+		Expect(metav1.IsControlledBy(applied, frontend)).To(BeTrue())
+		Expect(frontend.Status.ExtraResources).To(ConsistOf(aasdevv1.ExtraResourceReference{
+			APIVersion: "monitoring.coreos.com/v1", Kind: "ServiceMonitor", Name: "web-metrics",
+		}))
+		Expect(reconciler.Recorder.(*record.FakeRecorder).Events).To(Receive(Equal("Normal Created created ServiceMonitor/tenant-ns/web-metrics")))
+	})
+
+	It("deletes extra resources removed from the spec", func() {
+		setExtraResources(serviceMonitor("web-metrics"), serviceMonitor("web-probes"))
+		_, err := reconciler.reconcileExtraResources(ctx, frontend, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		setExtraResources(serviceMonitor("web-metrics"))
+		_, err = reconciler.reconcileExtraResources(ctx, frontend, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = getServiceMonitor("web-probes")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		_, err = getServiceMonitor("web-metrics")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(frontend.Status.ExtraResources).To(HaveLen(1))
+
+		setExtraResources()
+		_, err = reconciler.reconcileExtraResources(ctx, frontend, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = getServiceMonitor("web-metrics")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(frontend), frontend)).To(Succeed())
+		Expect(frontend.Status.ExtraResources).To(BeEmpty())
+	})
+
+	It("rejects kinds that aren't installed without applying anything", func() {
+		setExtraResources(serviceMonitor("web-metrics"), `{"apiVersion":"keda.sh/v1alpha1","kind":"ScaledObject","metadata":{"name":"web"}}`)
+
+		_, err := reconciler.reconcileExtraResources(ctx, frontend, logr.Discard())
+		reason, ok := terminalReason(err)
+		Expect(ok).To(BeTrue())
+		Expect(reason).To(Equal("UnknownKind"))
+		_, err = getServiceMonitor("web-metrics")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("rejects cluster scoped kinds and objects in other namespaces", func() {
+		setExtraResources(`{"apiVersion":"example.io/v1","kind":"Cluster","metadata":{"name":"web"}}`)
+		_, err := reconciler.reconcileExtraResources(ctx, frontend, logr.Discard())
+		reason, _ := terminalReason(err)
+		Expect(reason).To(Equal("ClusterScopedKind"))
+
+		setExtraResources(`{"apiVersion":"monitoring.coreos.com/v1","kind":"ServiceMonitor","metadata":{"name":"web","namespace":"other-ns"}}`)
+		_, err = reconciler.reconcileExtraResources(ctx, frontend, logr.Discard())
+		reason, _ = terminalReason(err)
+		Expect(reason).To(Equal("InvalidExtraResource"))
+	})
+
+	It("rejects objects the frontend doesn't control without taking them over", func() {
+		foreign := &unstructured.Unstructured{}
+		Expect(foreign.UnmarshalJSON([]byte(serviceMonitor("shared-metrics")))).To(Succeed())
+		foreign.SetNamespace("tenant-ns")
+		foreign.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: aasdevv1.GroupVersion.String(), Kind: "FrontendDeploy", Name: "api", UID: "api-uid", Controller: utils.DataTypePointerRef(true),
+		}})
+		_, err := dynamicClient.Resource(serviceMonitors).Namespace("tenant-ns").Create(ctx, foreign, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		setExtraResources(serviceMonitor("web-metrics"), serviceMonitor("shared-metrics"))
+		_, err = reconciler.reconcileExtraResources(ctx, frontend, logr.Discard())
+		reason, ok := terminalReason(err)
+		Expect(ok).To(BeTrue())
+		Expect(reason).To(Equal("InvalidExtraResource"))
+
+		current, err := getServiceMonitor("shared-metrics")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current.GetOwnerReferences()[0].Name).To(Equal("api"))
+		_, err = getServiceMonitor("web-metrics")
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	DescribeTable("rejects objects the operator generates",
+		func(manifest string) {
+			setExtraResources(manifest)
+			_, err := reconciler.reconcileExtraResources(ctx, frontend, logr.Discard())
+			reason, ok := terminalReason(err)
+			Expect(ok).To(BeTrue())
+			Expect(reason).To(Equal("InvalidExtraResource"))
+			Expect(err).To(MatchError(ContainSubstring("generated by the operator")))
+		},
+		Entry("the tenant controller ConfigMap", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"ingress-nginx-controller"},"data":{"allow-snippet-annotations":"true"}}`),
+		Entry("the tenant tcp services ConfigMap", `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"tenant-ns-tcp-service-cm"}}`),
+		Entry("the frontend Deployment", `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web"}}`),
+		Entry("the Service of a frontend", `{"apiVersion":"v1","kind":"Service","metadata":{"name":"api-frontend-svc"}}`),
+		Entry("the shared Ingress", `{"apiVersion":"networking.k8s.io/v1","kind":"Ingress","metadata":{"name":"tenant-ns-ingress-service"}}`),
+	)
+
+	It("has nothing to do without extra resources", func() {
+		_, err := reconciler.reconcileExtraResources(ctx, frontend, logr.Discard())
+		Expect(goerrors.Is(err, errUnchanged)).To(BeTrue())
+	})
+})
@@ -0,0 +1,207 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("Hibernation schedules", func() {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	nights := &aasdevv1.HibernationSchedule{
+		TimeZone: "Europe/Berlin",
+		Windows: []aasdevv1.HibernationWindow{
+			{Days: []string{"Mon", "Tue", "Wed", "Thu", "Fri"}, Start: "19:00", End: "07:00"},
+			{Days: []string{"Sat", "Sun"}, Start: "00:00", End: "00:00"},
+		},
+	}
+
+	It("hibernates inside a window and tells when it ends", func() {
+		// Tuesday 2024-03-05 23:00 in Berlin
+		state, err := utils.EvaluateHibernation(false, nights, time.Date(2024, 3, 5, 23, 0, 0, 0, berlin))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Hibernated).To(BeTrue())
+		Expect(state.Reason).To(Equal(utils.HibernatedBySchedule))
+		Expect(state.Next).To(BeTemporally("==", time.Date(2024, 3, 6, 7, 0, 0, 0, berlin)))
+	})
+
+	It("is awake outside the windows and tells when the next one starts", func() {
+		state, err := utils.EvaluateHibernation(false, nights, time.Date(2024, 3, 6, 12, 0, 0, 0, berlin))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Hibernated).To(BeFalse())
+		Expect(state.Next).To(BeTemporally("==", time.Date(2024, 3, 6, 19, 0, 0, 0, berlin)))
+	})
+
+	It("covers a whole weekend day and follows daylight saving time", func() {
+		// Sunday 2024-03-31, clocks go forward at 02:00 in Berlin
+		state, err := utils.EvaluateHibernation(false, nights, time.Date(2024, 3, 31, 12, 0, 0, 0, berlin))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Hibernated).To(BeTrue())
+		Expect(state.Next).To(BeTemporally("==", time.Date(2024, 4, 1, 0, 0, 0, 0, berlin)))
+	})
+
+	It("hibernates on spec.hibernate whatever the schedule", func() {
+		state, err := utils.EvaluateHibernation(true, nil, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Hibernated).To(BeTrue())
+		Expect(state.Reason).To(Equal(utils.HibernatedBySpec))
+		Expect(state.Next.IsZero()).To(BeTrue())
+	})
+
+	It("rejects unknown time zones", func() {
+		Expect(utils.ValidateHibernationSchedule(&aasdevv1.HibernationSchedule{TimeZone: "Mars/Olympus", Windows: nights.Windows})).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("FrontendDeploy suspend and hibernation", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		reconciler *FrontendDeployReconciler
+		request    reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		frontend := &aasdevv1.FrontendDeploy{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"},
+			Spec:       aasdevv1.FrontendDeploySpec{ImageName: "nginx", Port: 80, Replicas: 3},
+		}
+		tenant := &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+		request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(frontend)}
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(frontend, tenant).
+			WithStatusSubresource(&aasdevv1.FrontendDeploy{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		reconciler = &FrontendDeployReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+	})
+
+	update := func(obj client.Object, change func()) {
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(obj), obj)).To(Succeed())
+		change()
+		Expect(c.Update(ctx, obj)).To(Succeed())
+	}
+	replicas := func() int32 {
+		deployment := &appsv1.Deployment{}
+		Expect(c.Get(ctx, request.NamespacedName, deployment)).To(Succeed())
+		return *deployment.Spec.Replicas
+	}
+	condition := func(conditionType string) *metav1.Condition {
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, request.NamespacedName, frontend)).To(Succeed())
+		return meta.FindStatusCondition(frontend.Status.Conditions, conditionType)
+	}
+
+	It("leaves a suspended frontend alone", func() {
+		frontend := &aasdevv1.FrontendDeploy{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"}}
+		update(frontend, func() { frontend.Spec.Suspend = true })
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		err = c.Get(ctx, request.NamespacedName, &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(condition(aasdevv1.FrontendConditionSuspended).Status).To(Equal(metav1.ConditionTrue))
+
+		update(frontend, func() { frontend.Spec.Suspend = false })
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas()).To(Equal(int32(3)))
+		Expect(condition(aasdevv1.FrontendConditionSuspended)).To(BeNil())
+	})
+
+	It("scales to zero while the frontend or its tenant hibernates", func() {
+		frontend := &aasdevv1.FrontendDeploy{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"}}
+		update(frontend, func() { frontend.Spec.Hibernate = true })
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas()).To(BeZero())
+		Expect(condition(aasdevv1.FrontendConditionHibernated).Reason).To(Equal(utils.HibernatedBySpec))
+		Expect(condition(aasdevv1.FrontendConditionReady).Reason).To(Equal("Hibernated"))
+		Expect(condition(aasdevv1.FrontendConditionRouted).Reason).To(Equal("Hibernated"))
+
+		update(frontend, func() { frontend.Spec.Hibernate = false })
+		tenant := &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+		update(tenant, func() { tenant.Spec.Hibernate = true })
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas()).To(BeZero())
+		Expect(condition(aasdevv1.FrontendConditionHibernated).Reason).To(Equal(utils.HibernatedByTenant))
+
+		update(tenant, func() { tenant.Spec.Hibernate = false })
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas()).To(Equal(int32(3)))
+		Expect(condition(aasdevv1.FrontendConditionHibernated).Status).To(Equal(metav1.ConditionFalse))
+		events := reconciler.Recorder.(*record.FakeRecorder).Events
+		var recorded []string
+		for len(events) > 0 {
+			recorded = append(recorded, <-events)
+		}
+		Expect(recorded).To(ContainElement(HavePrefix("Normal Hibernated")))
+		Expect(recorded).To(ContainElement(HavePrefix("Normal Resumed")))
+	})
+
+	It("requeues at the next window boundary", func() {
+		frontend := &aasdevv1.FrontendDeploy{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"}}
+		update(frontend, func() {
+			frontend.Spec.HibernationSchedule = &aasdevv1.HibernationSchedule{Windows: []aasdevv1.HibernationWindow{
+				{Days: []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}, Start: "00:00", End: "00:00"},
+			}}
+		})
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas()).To(BeZero())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(result.RequeueAfter).To(BeNumerically("<=", 24*time.Hour))
+	})
+
+	It("rejects a schedule in an unknown time zone", func() {
+		frontend := &aasdevv1.FrontendDeploy{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"}}
+		update(frontend, func() {
+			frontend.Spec.HibernationSchedule = &aasdevv1.HibernationSchedule{TimeZone: "Mars/Olympus", Windows: []aasdevv1.HibernationWindow{
+				{Days: []string{"Sat"}, Start: "00:00", End: "00:00"},
+			}}
+		})
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(condition(aasdevv1.FrontendConditionValid).Reason).To(Equal("InvalidHibernationSchedule"))
+	})
+})
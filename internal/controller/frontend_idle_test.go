@@ -0,0 +1,188 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+// fixedRequestCounter reports the same request count for every Service.
+type fixedRequestCounter struct {
+	requests int64
+}
+
+func (f *fixedRequestCounter) Requests(context.Context, string, string) (int64, error) {
+	return f.requests, nil
+}
+
+var _ = Describe("FrontendDeploy idle policy", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		reconciler *FrontendDeployReconciler
+		counter    *fixedRequestCounter
+		request    reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		frontend := &aasdevv1.FrontendDeploy{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"},
+			Spec: aasdevv1.FrontendDeploySpec{
+				ImageName:  "nginx",
+				Port:       80,
+				Replicas:   3,
+				IdlePolicy: &aasdevv1.IdlePolicy{IdleMinutes: 10},
+			},
+		}
+		tenant := &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+		request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(frontend)}
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(frontend, tenant).
+			WithStatusSubresource(&aasdevv1.FrontendDeploy{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		counter = &fixedRequestCounter{requests: 5}
+		reconciler = &FrontendDeployReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100), RequestCounter: counter}
+	})
+
+	frontend := func() *aasdevv1.FrontendDeploy {
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, request.NamespacedName, frontend)).To(Succeed())
+		return frontend
+	}
+	setLastRequest := func(requests int64, at time.Time) {
+		updated := frontend()
+		updated.Status.Idle = &aasdevv1.IdleStatus{Requests: requests, LastRequestTime: metav1.NewTime(at)}
+		Expect(c.Status().Update(ctx, updated)).To(Succeed())
+	}
+	replicas := func() int32 {
+		deployment := &appsv1.Deployment{}
+		Expect(c.Get(ctx, request.NamespacedName, deployment)).To(Succeed())
+		return *deployment.Spec.Replicas
+	}
+	setAvailable := func(replicas int32) {
+		deployment := &appsv1.Deployment{}
+		Expect(c.Get(ctx, request.NamespacedName, deployment)).To(Succeed())
+		deployment.Status.AvailableReplicas = replicas
+		Expect(c.Status().Update(ctx, deployment)).To(Succeed())
+	}
+	dedicatedIngress := func() (*networkingv1.Ingress, error) {
+		ingress := &networkingv1.Ingress{}
+		err := c.Get(ctx, client.ObjectKey{Name: utils.FrontendIngressSuffixedString("web"), Namespace: "tenant-ns"}, ingress)
+		return ingress, err
+	}
+
+	It("starts the idle timer once the policy is seen and keeps reading traffic", func() {
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(result.RequeueAfter).To(BeNumerically("<=", idleCheckPeriod))
+		Expect(frontend().Status.Idle.Requests).To(Equal(int64(5)))
+		Expect(replicas()).To(Equal(int32(3)))
+
+		// new requests move the last request time
+		setLastRequest(5, time.Now().Add(-9*time.Minute))
+		counter.requests = 7
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(frontend().Status.Idle.Requests).To(Equal(int64(7)))
+		Expect(frontend().Status.Idle.LastRequestTime.Time).To(BeTemporally("~", time.Now(), time.Minute))
+		Expect(meta.IsStatusConditionTrue(frontend().Status.Conditions, aasdevv1.FrontendConditionIdle)).To(BeFalse())
+	})
+
+	It("scales an idle frontend to zero behind the activator and wakes it on request", func() {
+		setLastRequest(5, time.Now().Add(-20*time.Minute))
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas()).To(BeZero())
+		Expect(meta.IsStatusConditionTrue(frontend().Status.Conditions, aasdevv1.FrontendConditionIdle)).To(BeTrue())
+		Expect(meta.FindStatusCondition(frontend().Status.Conditions, aasdevv1.FrontendConditionReady).Reason).To(Equal("Idle"))
+		Expect(meta.FindStatusCondition(frontend().Status.Conditions, aasdevv1.FrontendConditionRouted).Reason).To(Equal("WakeOnRequest"))
+
+		ingress, err := dedicatedIngress()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name).To(Equal(utils.ACTIVATOR))
+		Expect(ingress.Annotations).To(HaveKeyWithValue(utils.UPSTREAM_VHOST_ANNOTATION, "web.tenant-ns"))
+
+		// the activator records the request, the frontend scales up behind it
+		setLastRequest(5, time.Now())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas()).To(Equal(int32(3)))
+		Expect(meta.IsStatusConditionTrue(frontend().Status.Conditions, aasdevv1.FrontendConditionIdle)).To(BeFalse())
+		_, err = dedicatedIngress()
+		Expect(err).NotTo(HaveOccurred())
+
+		// once a replica is available the path leads to the frontend again
+		setAvailable(1)
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = dedicatedIngress()
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(meta.FindStatusCondition(frontend().Status.Conditions, aasdevv1.FrontendConditionRouted).Reason).To(Equal("Published"))
+
+		events := reconciler.Recorder.(*record.FakeRecorder).Events
+		var recorded []string
+		for len(events) > 0 {
+			recorded = append(recorded, <-events)
+		}
+		Expect(recorded).To(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + EventReasonIdled)))
+		Expect(recorded).To(ContainElement(HavePrefix(corev1.EventTypeNormal + " " + EventReasonWoken)))
+	})
+
+	It("clears the idle status when the policy is removed", func() {
+		setLastRequest(5, time.Now().Add(-20*time.Minute))
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas()).To(BeZero())
+
+		updated := frontend()
+		updated.Spec.IdlePolicy = nil
+		Expect(c.Update(ctx, updated)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas()).To(Equal(int32(3)))
+		Expect(frontend().Status.Idle).To(BeNil())
+		Expect(meta.FindStatusCondition(frontend().Status.Conditions, aasdevv1.FrontendConditionIdle)).To(BeNil())
+	})
+})
@@ -0,0 +1,302 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("FrontendDeploy routing", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		reconciler *FrontendDeployReconciler
+		frontends  []reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		frontends = nil
+		var objs []client.Object
+		for _, name := range []string{"web", "admin"} {
+			frontend := &aasdevv1.FrontendDeploy{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "tenant-ns"},
+				Spec:       aasdevv1.FrontendDeploySpec{ImageName: "nginx", Port: 80},
+			}
+			objs = append(objs, frontend)
+			frontends = append(frontends, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(frontend)})
+		}
+		objs = append(objs, &aasdevv1.FrontendDeploy{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other-ns"}})
+
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&aasdevv1.FrontendDeploy{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		reconciler = &FrontendDeployReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+	})
+
+	setAvailable := func(request reconcile.Request, replicas int32) {
+		deployment := &appsv1.Deployment{}
+		Expect(c.Get(ctx, request.NamespacedName, deployment)).To(Succeed())
+		deployment.Status.AvailableReplicas = replicas
+		Expect(c.Status().Update(ctx, deployment)).To(Succeed())
+	}
+	routed := func(request reconcile.Request) *metav1.Condition {
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, request.NamespacedName, frontend)).To(Succeed())
+		return meta.FindStatusCondition(frontend.Status.Conditions, aasdevv1.FrontendConditionRouted)
+	}
+	sharedIngress := func() (*networkingv1.Ingress, error) {
+		shared := &networkingv1.Ingress{}
+		err := c.Get(ctx, types.NamespacedName{Name: utils.SharedIngressName("tenant-ns"), Namespace: "tenant-ns"}, shared)
+		return shared, err
+	}
+
+	It("waits for the SandOpsIngress and routes the frontend once it appears", func() {
+		_, err := reconciler.Reconcile(ctx, frontends[0])
+		Expect(err).NotTo(HaveOccurred())
+
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, frontends[0].NamespacedName, frontend)).To(Succeed())
+		waiting := meta.FindStatusCondition(frontend.Status.Conditions, aasdevv1.FrontendConditionWaitingForIngress)
+		Expect(waiting).NotTo(BeNil())
+		Expect(waiting.Status).To(Equal(metav1.ConditionTrue))
+		Expect(meta.FindStatusCondition(frontend.Status.Conditions, aasdevv1.FrontendConditionStalled)).To(BeNil())
+
+		ingress := &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+		Expect(c.Create(ctx, ingress)).To(Succeed())
+		requests := reconciler.tenantToFrontends(ctx, ingress)
+		Expect(requests).To(ConsistOf(frontends))
+
+		for _, request := range requests {
+			_, err = reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			setAvailable(request, 1)
+		}
+		for _, request := range requests {
+			_, err = reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(c.Get(ctx, frontends[0].NamespacedName, frontend)).To(Succeed())
+		Expect(meta.FindStatusCondition(frontend.Status.Conditions, aasdevv1.FrontendConditionWaitingForIngress)).To(BeNil())
+
+		shared, err := sharedIngress()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shared.Spec.Rules[0].HTTP.Paths).To(HaveLen(2))
+	})
+
+	It("publishes the path once a replica is available and withdraws it when none is", func() {
+		Expect(c.Create(ctx, &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}})).To(Succeed())
+		web := frontends[0]
+
+		_, err := reconciler.Reconcile(ctx, web)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = sharedIngress()
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(routed(web).Status).To(Equal(metav1.ConditionFalse))
+		Expect(routed(web).Reason).To(Equal("NoAvailableReplicas"))
+
+		setAvailable(web, 1)
+		_, err = reconciler.Reconcile(ctx, web)
+		Expect(err).NotTo(HaveOccurred())
+		shared, err := sharedIngress()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shared.Spec.Rules[0].HTTP.Paths).To(HaveLen(1))
+		Expect(routed(web).Status).To(Equal(metav1.ConditionTrue))
+
+		setAvailable(web, 0)
+		_, err = reconciler.Reconcile(ctx, web)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = sharedIngress()
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(routed(web).Reason).To(Equal("NoAvailableReplicas"))
+	})
+
+	It("routes frontends in maintenance to the maintenance backend", func() {
+		tenant := &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+		Expect(c.Create(ctx, tenant)).To(Succeed())
+		web, admin := frontends[0], frontends[1]
+
+		// in maintenance the path is published even without a replica
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, web.NamespacedName, frontend)).To(Succeed())
+		frontend.Spec.Maintenance = true
+		Expect(c.Update(ctx, frontend)).To(Succeed())
+		_, err := reconciler.Reconcile(ctx, web)
+		Expect(err).NotTo(HaveOccurred())
+		shared, err := sharedIngress()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shared.Spec.Rules[0].HTTP.Paths).To(HaveLen(1))
+		Expect(shared.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name).To(Equal(utils.MAINTENANCE_BACKEND))
+		Expect(routed(web).Status).To(Equal(metav1.ConditionTrue))
+		Expect(routed(web).Reason).To(Equal("Maintenance"))
+
+		// the tenant toggle covers every frontend
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(tenant), tenant)).To(Succeed())
+		tenant.Spec.Maintenance = true
+		Expect(c.Update(ctx, tenant)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, admin)
+		Expect(err).NotTo(HaveOccurred())
+		shared, err = sharedIngress()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shared.Spec.Rules[0].HTTP.Paths).To(HaveLen(2))
+		Expect(routed(admin).Reason).To(Equal("Maintenance"))
+
+		// leaving maintenance hands the path back to the available frontend
+		tenant.Spec.Maintenance = false
+		Expect(c.Update(ctx, tenant)).To(Succeed())
+		Expect(c.Get(ctx, web.NamespacedName, frontend)).To(Succeed())
+		frontend.Spec.Maintenance = false
+		Expect(c.Update(ctx, frontend)).To(Succeed())
+		setAvailable(web, 1)
+		_, err = reconciler.Reconcile(ctx, web)
+		Expect(err).NotTo(HaveOccurred())
+		shared, err = sharedIngress()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shared.Spec.Rules[0].HTTP.Paths).To(HaveLen(1))
+		Expect(shared.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name).To(Equal(utils.FrontendSVCSuffixedString("web")))
+		Expect(routed(web).Reason).To(Equal("Published"))
+	})
+
+	It("routes a rate limited frontend through an Ingress of its own", func() {
+		tenant := &aasdevv1.SandOpsIngress{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"},
+			Spec: aasdevv1.SandOpsIngressSpec{
+				RateLimit: &aasdevv1.TenantRateLimit{RateLimit: aasdevv1.RateLimit{RequestsPerSecond: 10, Connections: 5}},
+			},
+		}
+		Expect(c.Create(ctx, tenant)).To(Succeed())
+		web, admin := frontends[0], frontends[1]
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, web.NamespacedName, frontend)).To(Succeed())
+		frontend.Spec.RateLimit = &aasdevv1.RateLimit{RequestsPerSecond: 50}
+		Expect(c.Update(ctx, frontend)).To(Succeed())
+
+		for _, request := range frontends {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			setAvailable(request, 1)
+			_, err = reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		dedicated := &networkingv1.Ingress{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: utils.FrontendIngressSuffixedString("web"), Namespace: "tenant-ns"}, dedicated)).To(Succeed())
+		Expect(dedicated.Annotations).To(HaveKeyWithValue(utils.LIMIT_RPS_ANNOTATION, "50"))
+		Expect(dedicated.Annotations).To(HaveKeyWithValue(utils.LIMIT_CONNECTIONS_ANNOTATION, "5"))
+		Expect(routed(web).Status).To(Equal(metav1.ConditionTrue))
+
+		// the other frontend stays on the shared Ingress with the tenant defaults
+		shared, err := sharedIngress()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shared.Spec.Rules[0].HTTP.Paths).To(HaveLen(1))
+		Expect(shared.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name).To(Equal(utils.FrontendSVCSuffixedString("admin")))
+		Expect(shared.Annotations).To(HaveKeyWithValue(utils.LIMIT_RPS_ANNOTATION, "10"))
+		Expect(routed(admin).Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("routes a frontend with a host through an Ingress of its own", func() {
+		Expect(c.Create(ctx, &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}})).To(Succeed())
+		web := frontends[0]
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, web.NamespacedName, frontend)).To(Succeed())
+		frontend.Spec.Host = "shop.example.com"
+		Expect(c.Update(ctx, frontend)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, web)
+		Expect(err).NotTo(HaveOccurred())
+		setAvailable(web, 1)
+		_, err = reconciler.Reconcile(ctx, web)
+		Expect(err).NotTo(HaveOccurred())
+
+		dedicated := &networkingv1.Ingress{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: utils.FrontendIngressSuffixedString("web"), Namespace: "tenant-ns"}, dedicated)).To(Succeed())
+		Expect(dedicated.Spec.Rules[0].Host).To(Equal("shop.example.com"))
+		_, err = sharedIngress()
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(routed(web).Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("keeps the response headers of a frontend in a ConfigMap of its own", func() {
+		Expect(c.Create(ctx, &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}})).To(Succeed())
+		web := frontends[0]
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, web.NamespacedName, frontend)).To(Succeed())
+		frontend.Spec.ResponseHeaders = map[string]string{"X-Frame-Options": "DENY"}
+		Expect(c.Update(ctx, frontend)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, web)
+		Expect(err).NotTo(HaveOccurred())
+		setAvailable(web, 1)
+		_, err = reconciler.Reconcile(ctx, web)
+		Expect(err).NotTo(HaveOccurred())
+
+		headersKey := types.NamespacedName{Name: utils.FrontendHeadersSuffixedString("web"), Namespace: "tenant-ns"}
+		headers := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, headersKey, headers)).To(Succeed())
+		Expect(headers.Data).To(Equal(map[string]string{"X-Frame-Options": "DENY"}))
+		dedicated := &networkingv1.Ingress{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: utils.FrontendIngressSuffixedString("web"), Namespace: "tenant-ns"}, dedicated)).To(Succeed())
+		Expect(dedicated.Annotations).To(HaveKeyWithValue(utils.CUSTOM_HEADERS_ANNOTATION, "tenant-ns/"+headersKey.Name))
+
+		Expect(c.Get(ctx, web.NamespacedName, frontend)).To(Succeed())
+		frontend.Spec.ResponseHeaders = nil
+		Expect(c.Update(ctx, frontend)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, web)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = c.Get(ctx, headersKey, &corev1.ConfigMap{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("maps only the shared Ingress of the tenant to its frontends", func() {
+		shared := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: utils.SharedIngressName("tenant-ns"), Namespace: "tenant-ns"}}
+		Expect(reconciler.tenantToFrontends(ctx, shared)).To(ConsistOf(frontends))
+
+		dedicated := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: utils.FrontendIngressSuffixedString("web"), Namespace: "tenant-ns"}}
+		Expect(reconciler.tenantToFrontends(ctx, dedicated)).To(BeEmpty())
+
+		misplaced := &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "tenant-ns"}}
+		Expect(reconciler.tenantToFrontends(ctx, misplaced)).To(BeEmpty())
+	})
+})
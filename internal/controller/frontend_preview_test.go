@@ -0,0 +1,210 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("FrontendPreview", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		reconciler *FrontendPreviewReconciler
+		preview    *aasdevv1.FrontendPreview
+		request    reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		base := &aasdevv1.FrontendDeploy{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"},
+			Spec: aasdevv1.FrontendDeploySpec{
+				ImageName: "shop:1.0",
+				Port:      8080,
+				Replicas:  3,
+				IsHost:    true,
+				EnvironmentVarialbles: []aasdevv1.EnvironmentVariable{
+					{Name: "API_URL", Value: "https://api.example.com"},
+					{Name: "LOG_LEVEL", Value: "info"},
+				},
+			},
+		}
+		preview = &aasdevv1.FrontendPreview{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-pr-42", Namespace: "tenant-ns", UID: "preview-uid", CreationTimestamp: metav1.Now()},
+			Spec: aasdevv1.FrontendPreviewSpec{
+				BaseRef:   "web",
+				ImageName: "shop:pr-42",
+				EnvironmentVariables: []aasdevv1.EnvironmentVariable{
+					{Name: "API_URL", Value: "https://staging.example.com"},
+					{Name: "PREVIEW", Value: "true"},
+				},
+				TTL: &metav1.Duration{Duration: 72 * time.Hour},
+			},
+		}
+		request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(preview)}
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(base, preview).
+			WithStatusSubresource(&aasdevv1.FrontendPreview{}, &aasdevv1.FrontendDeploy{}, &corev1.Service{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		reconciler = &FrontendPreviewReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+	})
+
+	current := func() *aasdevv1.FrontendPreview {
+		current := &aasdevv1.FrontendPreview{}
+		Expect(c.Get(ctx, request.NamespacedName, current)).To(Succeed())
+		return current
+	}
+	ready := func() *metav1.Condition {
+		return meta.FindStatusCondition(current().Status.Conditions, aasdevv1.FrontendPreviewConditionReady)
+	}
+
+	It("clones the base frontend with the image and environment of the preview", func() {
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "web-pr-42-preview", Namespace: "tenant-ns"}, frontend)).To(Succeed())
+		Expect(frontend.Spec.ImageName).To(Equal("shop:pr-42"))
+		Expect(frontend.Spec.Port).To(Equal(int32(8080)))
+		Expect(frontend.Spec.Replicas).To(Equal(int32(1)))
+		Expect(frontend.Spec.IsHost).To(BeFalse())
+		Expect(frontend.Spec.EnvironmentVarialbles).To(Equal([]aasdevv1.EnvironmentVariable{
+			{Name: "API_URL", Value: "https://staging.example.com"},
+			{Name: "LOG_LEVEL", Value: "info"},
+			{Name: "PREVIEW", Value: "true"},
+		}))
+		Expect(metav1.IsControlledBy(frontend, preview)).To(BeTrue())
+		Expect(frontend.Labels).To(HaveKeyWithValue(utils.PREVIEW_NAME_LABEL, "web-pr-42"))
+
+		status := current().Status
+		Expect(status.FrontendName).To(Equal("web-pr-42-preview"))
+		Expect(status.Path).To(Equal("/web-pr-42-preview/"))
+		Expect(status.URL).To(BeEmpty())
+		Expect(status.ExpiresAt.Time).To(BeTemporally("~", preview.CreationTimestamp.Add(72*time.Hour), time.Second))
+		Expect(ready().Reason).To(Equal("Deploying"))
+	})
+
+	It("publishes the URL once the tenant ingress controller has an address", func() {
+		service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: utils.INGRESS_NGINX_CONTROLLER, Namespace: "tenant-ns"}}
+		Expect(c.Create(ctx, service)).To(Succeed())
+		service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}}
+		Expect(c.Status().Update(ctx, service)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current().Status.URL).To(Equal("http://203.0.113.10/web-pr-42-preview/"))
+	})
+
+	It("publishes the URL under the host of the base", func() {
+		base := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "web", Namespace: "tenant-ns"}, base)).To(Succeed())
+		base.Spec.Host = "shop.example.com"
+		Expect(c.Update(ctx, base)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current().Status.URL).To(Equal("http://shop.example.com/web-pr-42-preview/"))
+	})
+
+	It("runs regardless of a rollback or the switches of the base", func() {
+		base := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "web", Namespace: "tenant-ns"}, base)).To(Succeed())
+		revision := int64(3)
+		base.Spec.RollbackTo = &revision
+		base.Spec.Suspend = true
+		base.Spec.Hibernate = true
+		base.Spec.HibernationSchedule = &aasdevv1.HibernationSchedule{Windows: []aasdevv1.HibernationWindow{{Days: []string{"Sat"}, Start: "00:00", End: "00:00"}}}
+		base.Spec.Maintenance = true
+		Expect(c.Update(ctx, base)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "web-pr-42-preview", Namespace: "tenant-ns"}, frontend)).To(Succeed())
+		Expect(frontend.Spec.RollbackTo).To(BeNil())
+		Expect(frontend.Spec.Suspend).To(BeFalse())
+		Expect(frontend.Spec.Hibernate).To(BeFalse())
+		Expect(frontend.Spec.HibernationSchedule).To(BeNil())
+		Expect(frontend.Spec.Maintenance).To(BeFalse())
+	})
+
+	It("waits for a missing base and refuses a frontend it doesn't own", func() {
+		updated := current()
+		updated.Spec.BaseRef = "missing"
+		Expect(c.Update(ctx, updated)).To(Succeed())
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready().Reason).To(Equal("BaseNotFound"))
+
+		updated = current()
+		updated.Spec.BaseRef = "web"
+		Expect(c.Update(ctx, updated)).To(Succeed())
+		Expect(c.Create(ctx, &aasdevv1.FrontendDeploy{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-pr-42-preview", Namespace: "tenant-ns"},
+			Spec:       aasdevv1.FrontendDeploySpec{ImageName: "someone-else", Port: 80},
+		})).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready().Reason).To(Equal("NameTaken"))
+	})
+
+	It("deletes the preview once it expired", func() {
+		updated := current()
+		updated.Spec.ExpiresAt = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+		Expect(c.Update(ctx, updated)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		err = c.Get(ctx, request.NamespacedName, &aasdevv1.FrontendPreview{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(reconciler.Recorder.(*record.FakeRecorder).Events).To(Receive(HavePrefix("Normal " + EventReasonExpired)))
+	})
+
+	It("maps a base frontend to the previews cloned from it", func() {
+		base := &aasdevv1.FrontendDeploy{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"}}
+		Expect(reconciler.baseToPreviews(ctx, base)).To(ConsistOf(request))
+		other := &aasdevv1.FrontendDeploy{ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "tenant-ns"}}
+		Expect(reconciler.baseToPreviews(ctx, other)).To(BeEmpty())
+	})
+})
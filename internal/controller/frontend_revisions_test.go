@@ -0,0 +1,185 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("FrontendDeploy revisions", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		reconciler *FrontendDeployReconciler
+		request    reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		frontend := &aasdevv1.FrontendDeploy{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"},
+			Spec:       aasdevv1.FrontendDeploySpec{ImageName: "web:1", Port: 80, Replicas: 1},
+		}
+		tenant := &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+		request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(frontend)}
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(frontend, tenant).
+			WithStatusSubresource(&aasdevv1.FrontendDeploy{}, &appsv1.Deployment{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		reconciler = &FrontendDeployReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+	})
+
+	frontend := func() *aasdevv1.FrontendDeploy {
+		frontend := &aasdevv1.FrontendDeploy{}
+		Expect(c.Get(ctx, request.NamespacedName, frontend)).To(Succeed())
+		return frontend
+	}
+	update := func(mutate func(*aasdevv1.FrontendDeploySpec)) {
+		updated := frontend()
+		mutate(&updated.Spec)
+		Expect(c.Update(ctx, updated)).To(Succeed())
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+	}
+	revisions := func() map[int64]string {
+		list := &appsv1.ControllerRevisionList{}
+		Expect(c.List(ctx, list, client.InNamespace("tenant-ns"), client.MatchingLabels{utils.FRONTEND_NAME_LABEL: "web"})).To(Succeed())
+		byRevision := map[int64]string{}
+		for _, revision := range list.Items {
+			byRevision[revision.Revision] = revision.Labels[utils.REVISION_HASH_LABEL]
+		}
+		return byRevision
+	}
+	image := func() string {
+		deployment := &appsv1.Deployment{}
+		Expect(c.Get(ctx, request.NamespacedName, deployment)).To(Succeed())
+		return deployment.Spec.Template.Spec.Containers[0].Image
+	}
+	events := func() []string {
+		var recorded []string
+		for {
+			select {
+			case event := <-reconciler.Recorder.(*record.FakeRecorder).Events:
+				recorded = append(recorded, event)
+			default:
+				return recorded
+			}
+		}
+	}
+
+	It("records a revision per distinct spec and reuses the one of an identical spec", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(revisions()).To(HaveLen(1))
+		Expect(frontend().Status.UpdateRevision).To(Equal(int64(1)))
+
+		update(func(spec *aasdevv1.FrontendDeploySpec) { spec.ImageName = "web:2" })
+		Expect(revisions()).To(HaveLen(2))
+		Expect(frontend().Status.UpdateRevision).To(Equal(int64(2)))
+
+		// toggles that are not part of the rendered spec make no revision
+		update(func(spec *aasdevv1.FrontendDeploySpec) { spec.Maintenance = true })
+		Expect(revisions()).To(HaveLen(2))
+
+		first := revisions()[1]
+		update(func(spec *aasdevv1.FrontendDeploySpec) { spec.ImageName = "web:1" })
+		Expect(revisions()).To(HaveLen(2))
+		Expect(revisions()[3]).To(Equal(first))
+		Expect(frontend().Status.UpdateRevision).To(Equal(int64(3)))
+	})
+
+	It("restores the spec of the revision named in rollbackTo", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		update(func(spec *aasdevv1.FrontendDeploySpec) { spec.ImageName = "web:2" })
+		Expect(image()).To(Equal("web:2"))
+		events()
+
+		target := int64(1)
+		update(func(spec *aasdevv1.FrontendDeploySpec) {
+			spec.RollbackTo = &target
+			spec.Maintenance = true
+		})
+		rolledBack := frontend()
+		Expect(rolledBack.Spec.RollbackTo).To(BeNil())
+		Expect(rolledBack.Spec.ImageName).To(Equal("web:1"))
+		Expect(rolledBack.Spec.Maintenance).To(BeTrue())
+		Expect(events()).To(ContainElement(HavePrefix("Normal RolledBack")))
+
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image()).To(Equal("web:1"))
+		Expect(frontend().Status.UpdateRevision).To(Equal(int64(3)))
+	})
+
+	It("clears rollbackTo and reports a revision that doesn't exist", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		missing := int64(7)
+		update(func(spec *aasdevv1.FrontendDeploySpec) { spec.RollbackTo = &missing })
+		Expect(frontend().Spec.RollbackTo).To(BeNil())
+		Expect(frontend().Spec.ImageName).To(Equal("web:1"))
+		Expect(events()).To(ContainElement(HavePrefix("Warning RollbackFailed")))
+	})
+
+	It("prunes revisions beyond the history limit", func() {
+		limit := int32(2)
+		update(func(spec *aasdevv1.FrontendDeploySpec) { spec.RevisionHistoryLimit = &limit })
+		for _, tag := range []string{"web:2", "web:3", "web:4"} {
+			update(func(spec *aasdevv1.FrontendDeploySpec) { spec.ImageName = tag })
+		}
+		Expect(revisions()).To(HaveLen(2))
+		Expect(revisions()).To(HaveKey(int64(3)))
+		Expect(revisions()).To(HaveKey(int64(4)))
+	})
+
+	It("marks the update revision as current once the Deployment rolled out", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(frontend().Status.CurrentRevision).To(BeZero())
+
+		deployment := &appsv1.Deployment{}
+		Expect(c.Get(ctx, request.NamespacedName, deployment)).To(Succeed())
+		deployment.Status = appsv1.DeploymentStatus{ObservedGeneration: deployment.Generation, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+		Expect(c.Status().Update(ctx, deployment)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(frontend().Status.CurrentRevision).To(Equal(int64(1)))
+	})
+})
@@ -18,19 +18,31 @@ package controller
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/activator"
+	"sandtech.io/sand-ops/internal/metrics"
+	"sandtech.io/sand-ops/internal/tracing"
 	"sandtech.io/sand-ops/internal/utils"
 )
 
@@ -40,11 +52,17 @@ type FrontendDeployReconciler struct {
 	Scheme *runtime.Scheme
 	Log    logr.Logger
 	KubeClients
+	Recorder    record.EventRecorder
+	RateLimiter RateLimiterOptions
+	// RequestCounter reads the traffic of frontends with an idle policy.
+	RequestCounter activator.RequestCounter
 }
 
 // +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=frontenddeploys,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=frontenddeploys/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=frontenddeploys/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -56,6 +74,13 @@ type FrontendDeployReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.18.4/pkg/reconcile
 func (r *FrontendDeployReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracing.StartReconcile(ctx, "FrontendDeploy", req.NamespacedName)
+	result, err := r.reconcile(ctx, req)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (r *FrontendDeployReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	l := r.Log.WithValues("reconciling ", req.NamespacedName)
 
 	frontendDeploy := &controllerapi.FrontendDeploy{}
@@ -64,55 +89,203 @@ func (r *FrontendDeployReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	if err != nil {
 		if errors.IsNotFound(err) {
 			l.Info(fmt.Sprintf("could not find: %s/%s", req.Name, req.Namespace))
+			// the shared Ingress is rendered from the remaining frontends, dropping the deleted one's path
+			ingressResource, err := utils.GetIngress(req.Namespace, ctx, r.Client)
+			if errors.IsNotFound(err) {
+				return ctrl.Result{}, nil
+			}
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if _, err := r.reconcileSharedFrontendIngress(ctx, req.Namespace, ingressResource); err != nil && !goerrors.Is(err, errUnchanged) {
+				l.Error(err, "failed to remove deleted frontend from shared ingress")
+				recordFailed(r.Recorder, ingressResource, EventReasonApplyFailed, err)
+				return ctrl.Result{}, reconcileError(err)
+			}
 			return ctrl.Result{}, nil
 		}
 		l.Error(err, fmt.Sprintf("failed to get: %s/%s", req.Name, req.Namespace))
 		return ctrl.Result{}, err
 	}
 
-	frontendSvc, err := r.reconcileFrontendService(ctx, frontendDeploy, l)
+	if frontendDeploy.Spec.Suspend {
+		l.Info("frontend is suspended, skipping reconcile")
+		return ctrl.Result{}, r.setFrontendCondition(ctx, frontendDeploy, controllerapi.FrontendConditionSuspended, metav1.ConditionTrue, "Suspended", "spec.suspend is set")
+	}
+	if err := r.removeFrontendCondition(ctx, frontendDeploy, controllerapi.FrontendConditionSuspended); err != nil {
+		return ctrl.Result{}, err
+	}
 
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, fmt.Sprintf("failed to create frontend service: %s/%s", frontendSvc.Name, frontendSvc.Namespace))
-			return ctrl.Result{}, nil
+	if frontendDeploy.Spec.RollbackTo != nil {
+		// the restored spec is reconciled once the update comes back through the watch
+		if err := r.rollbackFrontend(ctx, frontendDeploy); err != nil {
+			l.Error(err, "failed to roll back frontend")
+			return ctrl.Result{}, r.frontendStepFailed(ctx, frontendDeploy, err)
 		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created frontend service: %s/%s", frontendSvc.Name, frontendSvc.Namespace))
+		return ctrl.Result{}, nil
 	}
 
-	frontendPod, err := r.reconcileFrontend(ctx, frontendDeploy, l)
+	if reason, err := validateFrontendSpec(frontendDeploy); err != nil {
+		l.Error(err, "invalid frontend spec")
+		recordFailed(r.Recorder, frontendDeploy, EventReasonInvalidSpec, err)
+		if statusErr := r.setFrontendCondition(ctx, frontendDeploy, controllerapi.FrontendConditionValid, metav1.ConditionFalse, reason, err.Error()); statusErr != nil {
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.setFrontendCondition(ctx, frontendDeploy, controllerapi.FrontendConditionValid, metav1.ConditionTrue, "Valid", ""); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.recordFrontendRevision(ctx, frontendDeploy); err != nil {
+		return ctrl.Result{}, err
+	}
 
+	now := time.Now()
+	nextHibernationChange, err := r.updateFrontendHibernation(ctx, frontendDeploy, now)
 	if err != nil {
-		if err.Error() == utils.FOUND {
-			return ctrl.Result{}, nil
-		} else {
-			l.Error(err, fmt.Sprintf("failed to create frontend deployment: %s/%s", frontendPod.Name, frontendPod.Namespace))
+		return ctrl.Result{}, err
+	}
+	nextIdleCheck, err := r.updateFrontendIdle(ctx, frontendDeploy, now, l)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	stepCtx, step := startFrontendStep(ctx, "service")
+	frontendSvc, err := r.reconcileFrontendService(stepCtx, frontendDeploy, l)
+	step.end(err)
+	if err != nil && !goerrors.Is(err, errUnchanged) {
+		l.Error(err, fmt.Sprintf("failed to apply frontend service: %s/%s", frontendSvc.Name, frontendSvc.Namespace))
+		return ctrl.Result{}, r.frontendStepFailed(ctx, frontendDeploy, err)
+	} else if err == nil {
+		l.Info(fmt.Sprintf("successfully applied frontend service: %s/%s", frontendSvc.Name, frontendSvc.Namespace))
+	}
+
+	stepCtx, step = startFrontendStep(ctx, "deployment")
+	frontendPod, err := r.reconcileFrontend(stepCtx, frontendDeploy, l)
+	step.end(err)
+	if err != nil && !goerrors.Is(err, errUnchanged) {
+		l.Error(err, fmt.Sprintf("failed to apply frontend deployment: %s/%s", frontendPod.Name, frontendPod.Namespace))
+		return ctrl.Result{}, r.frontendStepFailed(ctx, frontendDeploy, err)
+	} else if err == nil {
+		l.Info(fmt.Sprintf("successfully applied frontend deployment: %s/%s", frontendPod.Name, frontendPod.Namespace))
+	}
+
+	stepCtx, step = startFrontendStep(ctx, "headers")
+	frontendHeaders, err := r.reconcileFrontendHeaders(stepCtx, frontendDeploy, l)
+	step.end(err)
+	if err != nil && !goerrors.Is(err, errUnchanged) {
+		l.Error(err, fmt.Sprintf("failed to reconcile frontend response headers: %s/%s", frontendHeaders.Name, frontendHeaders.Namespace))
+		return ctrl.Result{}, r.frontendStepFailed(ctx, frontendDeploy, err)
+	} else if err == nil {
+		l.Info(fmt.Sprintf("successfully reconciled frontend response headers: %s/%s", frontendHeaders.Name, frontendHeaders.Namespace))
+	}
+
+	stepCtx, step = startFrontendStep(ctx, "ingress")
+	frontendIngress, err := r.reconcileFrontendIngress(stepCtx, frontendDeploy, l)
+	step.end(err)
+	switch {
+	case goerrors.Is(err, errWaitingForIngress):
+		// the SandOpsIngress watch brings the frontend back once the tenant has one
+		l.Info(err.Error())
+		if err := r.setFrontendCondition(ctx, frontendDeploy, controllerapi.FrontendConditionWaitingForIngress, metav1.ConditionTrue, "NoSandOpsIngress", err.Error()); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.setFrontendCondition(ctx, frontendDeploy, controllerapi.FrontendConditionRouted, metav1.ConditionFalse, "NoSandOpsIngress", err.Error()); err != nil {
+			return ctrl.Result{}, err
+		}
+	case err != nil && !goerrors.Is(err, errUnchanged):
+		l.Error(err, fmt.Sprintf("failed to apply frontend ingress: %s/%s", frontendIngress.Name, frontendIngress.Namespace))
+		return ctrl.Result{}, r.frontendStepFailed(ctx, frontendDeploy, err)
+	default:
+		if err == nil {
+			l.Info(fmt.Sprintf("successfully reconciled frontend ingress: %s/%s", frontendIngress.Name, frontendIngress.Namespace))
+		}
+		if err := r.removeFrontendCondition(ctx, frontendDeploy, controllerapi.FrontendConditionWaitingForIngress); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.updateFrontendRouting(ctx, frontendDeploy, frontendIngress); err != nil {
 			return ctrl.Result{}, err
 		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created frontend deployment: %s/%s", frontendPod.Name, frontendPod.Namespace))
 	}
 
-	frontendIngress, err := r.reconcileFrontendIngress(ctx, frontendDeploy, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, fmt.Sprintf("failed to create frontend ingress: %s/%s", frontendIngress.Name, frontendIngress.Namespace))
-			return ctrl.Result{}, nil
-		} else {
-			l.Info(fmt.Sprintf("successfully created frontend ingress: %s/%s", frontendIngress.Name, frontendIngress.Namespace))
-		}
+	stepCtx, step = startFrontendStep(ctx, "extra-resources")
+	extraResources, err := r.reconcileExtraResources(stepCtx, frontendDeploy, l)
+	step.end(err)
+	if err != nil && !goerrors.Is(err, errUnchanged) {
+		l.Error(err, fmt.Sprintf("failed to apply frontend extra resources: %s/%s", frontendDeploy.Name, frontendDeploy.Namespace))
+		return ctrl.Result{}, r.frontendStepFailed(ctx, frontendDeploy, err)
+	} else if err == nil {
+		l.Info(fmt.Sprintf("successfully applied %d frontend extra resources: %s/%s", len(extraResources), frontendDeploy.Name, frontendDeploy.Namespace))
+	}
+
+	if err := r.removeFrontendCondition(ctx, frontendDeploy, controllerapi.FrontendConditionStalled); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateFrontendReadiness(ctx, frontendDeploy); err != nil {
+		return ctrl.Result{}, err
 	}
+	return ctrl.Result{RequeueAfter: requeueAt(earliest(nextHibernationChange, nextIdleCheck), now)}, nil
+}
+
+// validateFrontendSpec checks what the CRD schema can't, returning the reason
+// of the Valid condition when the spec is invalid.
+func validateFrontendSpec(frontendPod *controllerapi.FrontendDeploy) (string, error) {
+	if err := utils.ValidateResponseHeaders(frontendPod.Spec.ResponseHeaders, frontendPod.Spec.CORS != nil); err != nil {
+		return "InvalidResponseHeaders", err
+	}
+	if err := utils.ValidateHibernationSchedule(frontendPod.Spec.HibernationSchedule); err != nil {
+		return "InvalidHibernationSchedule", err
+	}
+	return "", nil
+}
+
+// frontendStep measures and traces one step of a frontend reconcile.
+type frontendStep struct {
+	component string
+	start     time.Time
+	span      trace.Span
+}
 
-	return ctrl.Result{}, nil
+func startFrontendStep(ctx context.Context, component string) (context.Context, frontendStep) {
+	ctx, span := tracing.StartStep(ctx, component)
+	return ctx, frontendStep{component: component, start: time.Now(), span: span}
+}
+
+// end records the duration and outcome of the step. A step that found its
+// object up to date, or waits for the SandOpsIngress, didn't fail.
+func (s frontendStep) end(err error) {
+	if goerrors.Is(err, errUnchanged) || goerrors.Is(err, errWaitingForIngress) {
+		err = nil
+	}
+	metrics.ObserveStep(metrics.ControllerFrontendDeploy, s.component, time.Since(s.start), err)
+	tracing.End(s.span, err)
+}
+
+// frontendStepFailed records a terminal failure as the Stalled condition and
+// returns the error to hand to controller-runtime.
+func (r *FrontendDeployReconciler) frontendStepFailed(ctx context.Context, frontendPod *controllerapi.FrontendDeploy, err error) error {
+	recordFailed(r.Recorder, frontendPod, EventReasonApplyFailed, err)
+	reason, ok := terminalReason(err)
+	if !ok {
+		return err
+	}
+	if statusErr := r.setFrontendCondition(ctx, frontendPod, controllerapi.FrontendConditionStalled, metav1.ConditionTrue, reason, err.Error()); statusErr != nil {
+		return statusErr
+	}
+	return reconcileError(err)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *FrontendDeployReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		WithOptions(controller.Options{MaxConcurrentReconciles: 2}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 2, RateLimiter: NewRateLimiter(r.RateLimiter)}).
 		For(&controllerapi.FrontendDeploy{}).
 		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
 		Owns(&appsv1.Deployment{}).
+		Owns(&networkingv1.Ingress{}).
+		Watches(&controllerapi.SandOpsIngress{}, handler.EnqueueRequestsFromMapFunc(r.tenantToFrontends), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&networkingv1.Ingress{}, handler.EnqueueRequestsFromMapFunc(r.tenantToFrontends)).
 		Complete(r)
 }
@@ -0,0 +1,292 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline"
+	"sandtech.io/sand-ops/internal/tracing"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+// previewAddressPollPeriod is how often a preview looks for the external
+// address of the tenant ingress controller until it has one.
+const previewAddressPollPeriod = time.Minute
+
+// FrontendPreviewReconciler reconciles a FrontendPreview object
+type FrontendPreviewReconciler struct {
+	client.Client
+	Scheme      *runtime.Scheme
+	Log         logr.Logger
+	Recorder    record.EventRecorder
+	RateLimiter RateLimiterOptions
+}
+
+// +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=frontendpreviews,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=frontendpreviews/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=frontendpreviews/finalizers,verbs=update
+
+// Reconcile keeps the FrontendDeploy of a preview in line with its base and
+// deletes the preview once it expired. The frontend is owned by the preview
+// and goes with it.
+func (r *FrontendPreviewReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracing.StartReconcile(ctx, "FrontendPreview", req.NamespacedName)
+	result, err := r.reconcile(ctx, req)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (r *FrontendPreviewReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := r.Log.WithValues("reconciling ", req.NamespacedName)
+
+	preview := &controllerapi.FrontendPreview{}
+	if err := r.Get(ctx, req.NamespacedName, preview); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !preview.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	now := time.Now()
+	expiresAt := previewExpiry(preview)
+	if !now.Before(expiresAt) {
+		l.Info("preview expired, deleting it")
+		if err := r.Delete(ctx, preview); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		r.Recorder.Eventf(preview, corev1.EventTypeNormal, EventReasonExpired, "expired at %s", expiresAt.UTC().Format(time.RFC3339))
+		return ctrl.Result{}, nil
+	}
+	requeue := ctrl.Result{RequeueAfter: expiresAt.Sub(now)}
+
+	status := preview.Status.DeepCopy()
+	status.ExpiresAt = &metav1.Time{Time: expiresAt}
+	status.FrontendName = utils.PreviewFrontendName(preview.Name)
+
+	base := &controllerapi.FrontendDeploy{}
+	err := r.Get(ctx, client.ObjectKey{Name: preview.Spec.BaseRef, Namespace: preview.Namespace}, base)
+	if errors.IsNotFound(err) {
+		// the base watch brings the preview back once it exists
+		setPreviewReady(preview, status, metav1.ConditionFalse, "BaseNotFound", fmt.Sprintf("FrontendDeploy %s not found", preview.Spec.BaseRef))
+		return requeue, r.updatePreviewStatus(ctx, preview, status)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	frontend := &controllerapi.FrontendDeploy{}
+	err = r.Get(ctx, client.ObjectKey{Name: status.FrontendName, Namespace: preview.Namespace}, frontend)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if err == nil && !metav1.IsControlledBy(frontend, preview) {
+		setPreviewReady(preview, status, metav1.ConditionFalse, "NameTaken", fmt.Sprintf("FrontendDeploy %s isn't owned by the preview", status.FrontendName))
+		return requeue, r.updatePreviewStatus(ctx, preview, status)
+	}
+
+	desired := previewFrontend(preview, base)
+	action, err := pipeline.ServerSideApply(ctx, r.Client, desired)
+	if err != nil {
+		l.Error(err, "failed to apply preview frontend")
+		recordFailed(r.Recorder, preview, EventReasonApplyFailed, err)
+		return ctrl.Result{}, reconcileError(err)
+	}
+	recordApplied(r.Recorder, preview, action, pipeline.ObjectName(r.Client, desired))
+
+	status.Path = "/" + status.FrontendName + "/"
+	// the preview frontend is served under the host of the base when it has one
+	address := base.Spec.Host
+	if address == "" {
+		address, err = r.tenantAddress(ctx, preview.Namespace)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	status.URL = ""
+	if address != "" {
+		status.URL = "http://" + address + status.Path
+	} else if requeue.RequeueAfter > previewAddressPollPeriod {
+		// the tenant Service isn't watched, look again for the load balancer
+		requeue.RequeueAfter = previewAddressPollPeriod
+	}
+
+	// the preview is as ready as its frontend, apply returned it as stored
+	ready := meta.FindStatusCondition(desired.Status.Conditions, controllerapi.FrontendConditionReady)
+	if ready == nil {
+		setPreviewReady(preview, status, metav1.ConditionFalse, "Deploying", "the preview frontend is being deployed")
+	} else {
+		setPreviewReady(preview, status, ready.Status, ready.Reason, ready.Message)
+	}
+	return requeue, r.updatePreviewStatus(ctx, preview, status)
+}
+
+// previewExpiry is the earlier of the TTL and spec.expiresAt.
+func previewExpiry(preview *controllerapi.FrontendPreview) time.Time {
+	var expiresAt time.Time
+	if preview.Spec.TTL != nil {
+		expiresAt = preview.CreationTimestamp.Add(preview.Spec.TTL.Duration)
+	}
+	if preview.Spec.ExpiresAt != nil {
+		expiresAt = earliest(expiresAt, preview.Spec.ExpiresAt.Time)
+	}
+	return expiresAt
+}
+
+// previewFrontend clones the spec of base with the image and environment of
+// the preview. The preview gets a path of its own rather than the root path,
+// runs a single replica, and leaves the extra resources of the base alone as
+// their names would clash. A rollback or the suspend, hibernation and
+// maintenance switches of the base concern the base only, the preview runs
+// regardless.
+func previewFrontend(preview *controllerapi.FrontendPreview, base *controllerapi.FrontendDeploy) *controllerapi.FrontendDeploy {
+	spec := base.Spec.DeepCopy()
+	spec.ImageName = preview.Spec.ImageName
+	spec.EnvironmentVarialbles = mergeEnvironment(spec.EnvironmentVarialbles, preview.Spec.EnvironmentVariables)
+	spec.IsHost = false
+	spec.Replicas = 1
+	spec.ExtraResources = nil
+	spec.RollbackTo = nil
+	spec.Suspend = false
+	spec.Hibernate = false
+	spec.HibernationSchedule = nil
+	spec.Maintenance = false
+
+	return &controllerapi.FrontendDeploy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      utils.PreviewFrontendName(preview.Name),
+			Namespace: preview.Namespace,
+			Labels: map[string]string{
+				utils.PREVIEW_NAME_LABEL: preview.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         controllerapi.GroupVersion.String(),
+					Kind:               "FrontendPreview",
+					Name:               preview.Name,
+					UID:                preview.UID,
+					Controller:         utils.DataTypePointerRef(true),
+					BlockOwnerDeletion: utils.DataTypePointerRef(true),
+				},
+			},
+		},
+		Spec: *spec,
+	}
+}
+
+// mergeEnvironment returns base with overrides applied, replacing variables of
+// the same name in place and appending new ones.
+func mergeEnvironment(base, overrides []controllerapi.EnvironmentVariable) []controllerapi.EnvironmentVariable {
+	merged := append([]controllerapi.EnvironmentVariable{}, base...)
+	for _, override := range overrides {
+		replaced := false
+		for i := range merged {
+			if merged[i].Name == override.Name {
+				merged[i].Value = override.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, override)
+		}
+	}
+	return merged
+}
+
+// tenantAddress returns the external IP or hostname of the tenant ingress
+// controller, empty until the load balancer assigned one.
+func (r *FrontendPreviewReconciler) tenantAddress(ctx context.Context, namespace string) (string, error) {
+	service := &corev1.Service{}
+	err := r.Get(ctx, client.ObjectKey{Name: utils.INGRESS_NGINX_CONTROLLER, Namespace: namespace}, service)
+	if errors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.Hostname != "" {
+			return ingress.Hostname, nil
+		}
+		if ingress.IP != "" {
+			return ingress.IP, nil
+		}
+	}
+	return "", nil
+}
+
+func setPreviewReady(preview *controllerapi.FrontendPreview, status *controllerapi.FrontendPreviewStatus, conditionStatus metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               controllerapi.FrontendPreviewConditionReady,
+		Status:             conditionStatus,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: preview.Generation,
+	})
+}
+
+// updatePreviewStatus writes status when it differs from the one of preview.
+func (r *FrontendPreviewReconciler) updatePreviewStatus(ctx context.Context, preview *controllerapi.FrontendPreview, status *controllerapi.FrontendPreviewStatus) error {
+	if equality.Semantic.DeepEqual(&preview.Status, status) {
+		return nil
+	}
+	preview.Status = *status
+	return r.Status().Update(ctx, preview)
+}
+
+// baseToPreviews maps a FrontendDeploy to the previews cloned from it.
+func (r *FrontendPreviewReconciler) baseToPreviews(ctx context.Context, obj client.Object) []reconcile.Request {
+	previews := &controllerapi.FrontendPreviewList{}
+	if err := r.List(ctx, previews, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list the previews of frontend", "frontend", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range previews.Items {
+		if previews.Items[i].Spec.BaseRef == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&previews.Items[i])})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *FrontendPreviewReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{RateLimiter: NewRateLimiter(r.RateLimiter)}).
+		For(&controllerapi.FrontendPreview{}).
+		Owns(&controllerapi.FrontendDeploy{}).
+		Watches(&controllerapi.FrontendDeploy{}, handler.EnqueueRequestsFromMapFunc(r.baseToPreviews)).
+		Complete(r)
+}
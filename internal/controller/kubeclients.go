@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
@@ -14,56 +15,39 @@ import (
 	"k8s.io/client-go/rest"
 )
 
+// KubeClients are the typed and dynamic clients the reconcilers use next to
+// the controller-runtime client. They talk to the cluster the manager runs
+// against, in or out of cluster.
 type KubeClients struct {
 	CRDClientSet        *clientset.Clientset
 	KubernetesClientSet *kubernetes.Clientset
-	DynamicClientSet    *dynamic.DynamicClient
+	// DynamicClientSet applies objects of kinds the operator has no types
+	// for, such as the extra resources of a FrontendDeploy.
+	DynamicClientSet dynamic.Interface
 }
 
-func GetCRDClientSet() (*clientset.Clientset, error) {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return nil, err
-	}
-	client, err := clientset.NewForConfig(config)
-	if err != nil {
-		return client, err
-	}
-	return client, nil
+func GetCRDClientSet(config *rest.Config) (*clientset.Clientset, error) {
+	return clientset.NewForConfig(config)
 }
 
-func GetKubernetesClientSet() (*kubernetes.Clientset, error) {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return nil, err
-	}
-	client, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return client, err
-	}
-	return client, nil
+func GetKubernetesClientSet(config *rest.Config) (*kubernetes.Clientset, error) {
+	return kubernetes.NewForConfig(config)
 }
 
-func GetDynamicClient() (*dynamic.DynamicClient, error) {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return nil, err
-	}
-	dynamicClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		return dynamicClient, err
-	}
-	return dynamicClient, nil
+func GetDynamicClient(config *rest.Config) (*dynamic.DynamicClient, error) {
+	return dynamic.NewForConfig(config)
 }
 
-func LoadCRDs(l logr.Logger) {
-	crdClientSet, err := GetCRDClientSet()
-	if crdClientSet == nil {
+func LoadCRDs(config *rest.Config, l logr.Logger) {
+	crdClientSet, err := GetCRDClientSet(config)
+	if err != nil {
 		l.Error(err, "Failed to get CRD ClientSet")
+		return
 	}
 	crdList, err := crdClientSet.ApiextensionsV1().CustomResourceDefinitions().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
 		l.Error(err, "CRD List not found")
+		return
 	}
 
 	// 3. get new empty schema holder
@@ -85,23 +69,28 @@ func LoadCRDs(l logr.Logger) {
 	}
 }
 
-func GetAllClients() (KubeClients, error) {
-	CRDClient, err := GetCRDClientSet()
+// GetAllClients builds every client set from config, usually the rest.Config
+// of the manager, so kubeconfig, context and impersonation flags apply to them
+// as well. It fails when config is missing or a client can't be built.
+func GetAllClients(config *rest.Config) (KubeClients, error) {
+	if config == nil {
+		return KubeClients{}, fmt.Errorf("no rest config to build the kube clients from")
+	}
+	CRDClient, err := GetCRDClientSet(config)
 	if err != nil {
-		return KubeClients{}, err
+		return KubeClients{}, fmt.Errorf("building the CRD client set: %w", err)
 	}
-	KubernetesClient, err := GetKubernetesClientSet()
+	KubernetesClient, err := GetKubernetesClientSet(config)
 	if err != nil {
-		return KubeClients{}, err
+		return KubeClients{}, fmt.Errorf("building the kubernetes client set: %w", err)
 	}
-	DynamicClient, err := GetDynamicClient()
+	DynamicClient, err := GetDynamicClient(config)
 	if err != nil {
-		return KubeClients{}, err
+		return KubeClients{}, fmt.Errorf("building the dynamic client: %w", err)
 	}
 	return KubeClients{
 		CRDClientSet:        CRDClient,
 		KubernetesClientSet: KubernetesClient,
 		DynamicClientSet:    DynamicClient,
 	}, nil
-
 }
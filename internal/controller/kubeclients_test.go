@@ -0,0 +1,44 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/rest"
+)
+
+var _ = Describe("KubeClients", func() {
+	It("builds every client from the given config", func() {
+		clients, err := GetAllClients(&rest.Config{Host: "https://127.0.0.1:6443"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(clients.CRDClientSet).NotTo(BeNil())
+		Expect(clients.KubernetesClientSet).NotTo(BeNil())
+		Expect(clients.DynamicClientSet).NotTo(BeNil())
+		Expect(clients.KubernetesClientSet.CoreV1().RESTClient().Get().URL().Host).To(Equal("127.0.0.1:6443"))
+	})
+
+	It("fails without a config", func() {
+		_, err := GetAllClients(nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fails on a config clients can't be built from", func() {
+		_, err := GetAllClients(&rest.Config{Host: "https://127.0.0.1:6443", QPS: 1, Burst: 0})
+		Expect(err).To(HaveOccurred())
+	})
+})
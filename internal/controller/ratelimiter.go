@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+)
+
+// RateLimiterOptions configure how failed reconciles are retried.
+type RateLimiterOptions struct {
+	// BaseDelay is the delay before the first retry of a resource, doubled on
+	// every further failure.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries of a resource.
+	MaxDelay time.Duration
+	// QPS and Burst limit the retries of all resources together.
+	QPS   float64
+	Burst int
+}
+
+// DefaultRateLimiterOptions match the controller-runtime defaults, except for
+// a shorter MaxDelay so a half built tenant doesn't wait a quarter of an hour.
+var DefaultRateLimiterOptions = RateLimiterOptions{
+	BaseDelay: 5 * time.Millisecond,
+	MaxDelay:  5 * time.Minute,
+	QPS:       10,
+	Burst:     100,
+}
+
+// NewRateLimiter builds the workqueue rate limiter of a controller. A resource
+// is retried after the longer of its own exponential backoff and the overall
+// token bucket. Options left zero take their DefaultRateLimiterOptions value.
+func NewRateLimiter(opts RateLimiterOptions) ratelimiter.RateLimiter {
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = DefaultRateLimiterOptions.BaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultRateLimiterOptions.MaxDelay
+	}
+	if opts.QPS <= 0 {
+		opts.QPS = DefaultRateLimiterOptions.QPS
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = DefaultRateLimiterOptions.Burst
+	}
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(opts.BaseDelay, opts.MaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(opts.QPS), opts.Burst)},
+	)
+}
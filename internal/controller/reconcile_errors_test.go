@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+)
+
+var _ = Describe("Reconcile errors", func() {
+	var (
+		ctx        context.Context
+		testScheme *runtime.Scheme
+		// failApply makes applying an object fail with the returned error
+		failApply func(obj client.Object) error
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+		failApply = func(client.Object) error { return nil }
+	})
+
+	newClient := func(objs ...client.Object) client.Client {
+		funcs := pipelinetest.Interceptor()
+		funcs.Patch = func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			if err := failApply(obj); err != nil {
+				return err
+			}
+			return pipelinetest.ApplyPatch(ctx, c, obj, patch, opts...)
+		}
+		return fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&aasdevv1.SandOpsIngress{}, &aasdevv1.FrontendDeploy{}).
+			WithInterceptorFuncs(funcs).
+			Build()
+	}
+	invalid := func(obj client.Object) error {
+		return errors.NewInvalid(schema.GroupKind{Kind: "Deployment"}, obj.GetName(), field.ErrorList{
+			field.Invalid(field.NewPath("spec"), nil, "rejected"),
+		})
+	}
+	isTerminal := func(err error) bool {
+		return goerrors.Is(err, reconcile.TerminalError(nil))
+	}
+
+	Context("of a SandOpsIngress", func() {
+		var (
+			c          client.Client
+			reconciler *SandOpsIngressReconciler
+			request    reconcile.Request
+		)
+
+		BeforeEach(func() {
+			ingress := &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+			c = newClient(ingress)
+			reconciler = &SandOpsIngressReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+			request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)}
+		})
+
+		It("returns transient failures so they are retried with backoff", func() {
+			failApply = func(obj client.Object) error {
+				if _, ok := obj.(*corev1.Namespace); ok {
+					return errors.NewServiceUnavailable("try again")
+				}
+				return nil
+			}
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).To(HaveOccurred())
+			Expect(isTerminal(err)).To(BeFalse())
+
+			ingress := &aasdevv1.SandOpsIngress{}
+			Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+			Expect(meta.FindStatusCondition(ingress.Status.Conditions, aasdevv1.IngressConditionStalled)).To(BeNil())
+		})
+
+		It("records rejected objects as Stalled instead of retrying them", func() {
+			failApply = func(obj client.Object) error {
+				if _, ok := obj.(*appsv1.Deployment); ok {
+					return invalid(obj)
+				}
+				return nil
+			}
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(isTerminal(err)).To(BeTrue())
+
+			ingress := &aasdevv1.SandOpsIngress{}
+			Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+			stalled := meta.FindStatusCondition(ingress.Status.Conditions, aasdevv1.IngressConditionStalled)
+			Expect(stalled).NotTo(BeNil())
+			Expect(stalled.Status).To(Equal(metav1.ConditionTrue))
+			Expect(stalled.Reason).To(Equal("Rejected"))
+
+			failApply = func(client.Object) error { return nil }
+			_, err = reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+			Expect(meta.FindStatusCondition(ingress.Status.Conditions, aasdevv1.IngressConditionStalled)).To(BeNil())
+		})
+	})
+
+	Context("of a FrontendDeploy", func() {
+		var (
+			c          client.Client
+			reconciler *FrontendDeployReconciler
+			request    reconcile.Request
+		)
+
+		BeforeEach(func() {
+			frontend := &aasdevv1.FrontendDeploy{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "tenant-ns"},
+				Spec:       aasdevv1.FrontendDeploySpec{ImageName: "nginx", Port: 80},
+			}
+			c = newClient(frontend)
+			reconciler = &FrontendDeployReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+			request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(frontend)}
+		})
+
+		It("returns transient failures so they are retried with backoff", func() {
+			failApply = func(obj client.Object) error {
+				return errors.NewTimeoutError("try again", 1)
+			}
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).To(HaveOccurred())
+			Expect(isTerminal(err)).To(BeFalse())
+		})
+
+		It("records rejected objects as Stalled instead of retrying them", func() {
+			failApply = func(obj client.Object) error {
+				if _, ok := obj.(*corev1.Service); ok {
+					return invalid(obj)
+				}
+				return nil
+			}
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(isTerminal(err)).To(BeTrue())
+
+			frontend := &aasdevv1.FrontendDeploy{}
+			Expect(c.Get(ctx, request.NamespacedName, frontend)).To(Succeed())
+			stalled := meta.FindStatusCondition(frontend.Status.Conditions, aasdevv1.FrontendConditionStalled)
+			Expect(stalled).NotTo(BeNil())
+			Expect(stalled.Reason).To(Equal("Rejected"))
+			Expect(reconciler.Recorder.(*record.FakeRecorder).Events).To(Receive(HavePrefix("Warning ApplyFailed")))
+		})
+	})
+})
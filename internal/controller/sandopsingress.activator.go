@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// activatorServiceComponent brings the operator's activator into the tenant
+// namespace. An Ingress can only route to Services of its own namespace, so
+// idle frontends are routed to this ExternalName Service instead.
+func (r *SandOpsIngressReconciler) activatorServiceComponent(ingressDeployment *controllerapi.SandOpsIngress) pipeline.Component {
+	return pipeline.Component{
+		Name:      componentActivatorService,
+		DependsOn: []string{componentNamespace},
+		Object: func() client.Object {
+			return &corev1.Service{ObjectMeta: tenantObjectMeta(ingressDeployment, utils.ACTIVATOR)}
+		},
+		Desired: func(ctx context.Context, obj client.Object) error {
+			service := obj.(*corev1.Service)
+			setOwnedMeta(service, ingressDeployment, utils.IngressLabel(utils.ACTIVATOR))
+			externalName := r.ActivatorService
+			if externalName == "" {
+				externalName = utils.DEFAULT_ACTIVATOR_SERVICE
+			}
+			service.Spec.Type = corev1.ServiceTypeExternalName
+			service.Spec.ExternalName = externalName
+			service.Spec.Ports = []corev1.ServicePort{
+				{
+					Name:     "http",
+					Port:     80,
+					Protocol: corev1.ProtocolTCP,
+				},
+			}
+			return nil
+		},
+	}
+}
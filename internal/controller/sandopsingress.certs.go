@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// admissionSecretComponent keeps the ingress-nginx-admission Secret holding
+// a CA and a serving certificate for the admission Service, rotating both
+// before they expire.
+func (r *SandOpsIngressReconciler) admissionSecretComponent(ingressDeployment *controllerapi.SandOpsIngress) pipeline.Component {
+	return pipeline.Component{
+		Name:      componentAdmissionSecret,
+		DependsOn: []string{componentNamespace},
+		Object: func() client.Object {
+			return &corev1.Secret{ObjectMeta: tenantObjectMeta(ingressDeployment, utils.INGRESS_NGINX_ADMISSION)}
+		},
+		Desired: func(ctx context.Context, obj client.Object) error {
+			current, err := r.getAdmissionSecret(ctx, ingressDeployment)
+			if err != nil {
+				return err
+			}
+			var currentData map[string][]byte
+			if current != nil {
+				currentData = current.Data
+			}
+
+			secret := obj.(*corev1.Secret)
+			setOwnedMeta(secret, ingressDeployment, utils.IngressLabel(utils.ADMISSION_WEBHOOK))
+			secret.Type = corev1.SecretTypeOpaque
+
+			hosts := utils.AdmissionServiceHosts(utils.NSSuffixedNamespace(ingressDeployment.Name))
+			now := time.Now()
+			if utils.WebhookCertificateValid(currentData[utils.WebhookSecretCAKey], currentData[utils.WebhookSecretCertKey], hosts, now) {
+				// applied again as is, leaving the keys out would remove them
+				secret.Data = currentData
+				return nil
+			}
+
+			caPEM, certPEM, keyPEM, err := utils.GenerateWebhookCertificates(hosts, now)
+			if err != nil {
+				return err
+			}
+			data := map[string][]byte{
+				utils.WebhookSecretCAKey:   caPEM,
+				utils.WebhookSecretCertKey: certPEM,
+				utils.WebhookSecretKeyKey:  keyPEM,
+			}
+			if previousCA := currentData[utils.WebhookSecretCAKey]; len(previousCA) > 0 {
+				data[utils.WebhookSecretPreviousCAKey] = previousCA
+			}
+			secret.Data = data
+			return nil
+		},
+	}
+}
+
+// webhookComponent is the ingress-nginx ValidatingWebhookConfiguration,
+// trusting the CA from the admission Secret.
+func (r *SandOpsIngressReconciler) webhookComponent(ingressDeployment *controllerapi.SandOpsIngress) pipeline.Component {
+	return pipeline.Component{
+		Name:      componentWebhook,
+		DependsOn: []string{componentAdmissionSecret, componentAdmissionService},
+		Object: func() client.Object {
+			return &admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: utils.ClusterScopedName(utils.INGRESS_NGINX_ADMISSION, ingressDeployment.Name)}}
+		},
+		Desired: func(ctx context.Context, obj client.Object) error {
+			admissionSecret, err := r.getAdmissionSecret(ctx, ingressDeployment)
+			if err != nil {
+				return err
+			}
+			if admissionSecret == nil {
+				return fmt.Errorf("admission secret %s not created yet", utils.INGRESS_NGINX_ADMISSION)
+			}
+			caBundle := utils.WebhookCABundle(admissionSecret.Data[utils.WebhookSecretCAKey], admissionSecret.Data[utils.WebhookSecretPreviousCAKey], time.Now())
+
+			webhookConfig := obj.(*admissionregistrationv1.ValidatingWebhookConfiguration)
+			setOwnedMeta(webhookConfig, ingressDeployment, utils.IngressLabel(utils.ADMISSION_WEBHOOK))
+			allScopes := admissionregistrationv1.AllScopes
+			webhookConfig.Webhooks = []admissionregistrationv1.ValidatingWebhook{
+				{
+					Name: "validate.nginx.ingress.kubernetes.io",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						Service: &admissionregistrationv1.ServiceReference{
+							Name:      utils.INGRESS_NGINX_CONTROLLER_ADMISSION,
+							Namespace: utils.NSSuffixedNamespace(ingressDeployment.Name),
+							Path:      utils.DataTypePointerRef("/networking/v1/ingresses"),
+							Port:      utils.DataTypePointerRef(int32(443)),
+						},
+						CABundle: caBundle,
+					},
+					FailurePolicy: (*admissionregistrationv1.FailurePolicyType)(utils.DataTypePointerRef("Fail")),
+					MatchPolicy:   (*admissionregistrationv1.MatchPolicyType)(utils.DataTypePointerRef("Equivalent")),
+					Rules: []admissionregistrationv1.RuleWithOperations{
+						{
+							Operations: []admissionregistrationv1.OperationType{"CREATE", "UPDATE"},
+							Rule: admissionregistrationv1.Rule{
+								APIGroups:   []string{"networking.k8s.io"},
+								APIVersions: []string{"v1"},
+								Resources:   []string{"ingresses"},
+								Scope:       &allScopes,
+							},
+						},
+					},
+					NamespaceSelector: &metav1.LabelSelector{},
+					ObjectSelector:    &metav1.LabelSelector{},
+					SideEffects: func(s admissionregistrationv1.SideEffectClass) *admissionregistrationv1.SideEffectClass {
+						return &s
+					}(admissionregistrationv1.SideEffectClassNone),
+					TimeoutSeconds:          utils.DataTypePointerRef(int32(10)),
+					AdmissionReviewVersions: []string{"v1"},
+				},
+			}
+			return nil
+		},
+	}
+}
+
+// getAdmissionSecret returns the admission Secret, nil if it doesn't exist yet.
+func (r *SandOpsIngressReconciler) getAdmissionSecret(ctx context.Context, ingressDeployment *controllerapi.SandOpsIngress) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: utils.INGRESS_NGINX_ADMISSION, Namespace: utils.NSSuffixedNamespace(ingressDeployment.Name)}, secret)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// admissionCertRequeue is how long until the admission certificate is due
+// for rotation.
+func (r *SandOpsIngressReconciler) admissionCertRequeue(ctx context.Context, ingressDeployment *controllerapi.SandOpsIngress) (time.Duration, error) {
+	secret, err := r.getAdmissionSecret(ctx, ingressDeployment)
+	if err != nil || secret == nil {
+		return 0, err
+	}
+	renewAt, err := utils.WebhookCertRenewAtPEM(secret.Data[utils.WebhookSecretCertKey])
+	if err != nil {
+		return 0, err
+	}
+	return time.Until(renewAt), nil
+}
+
+// removeCertgenResources deletes the kube-webhook-certgen Jobs and their RBAC
+// left behind by earlier versions of the operator. Their ingress-nginx-admission
+// ClusterRole is left alone: it carried no label telling it apart from the one
+// of an upstream ingress-nginx install.
+func (r *SandOpsIngressReconciler) removeCertgenResources(ctx context.Context, ingressDeployment *controllerapi.SandOpsIngress, l logr.Logger) error {
+	namespace := utils.NSSuffixedNamespace(ingressDeployment.Name)
+	legacy := []client.Object{
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "ingress-nginx-admission-create", Namespace: namespace}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "ingress-nginx-admission-patch", Namespace: namespace}},
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: utils.INGRESS_NGINX_ADMISSION, Namespace: namespace}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: utils.INGRESS_NGINX_ADMISSION, Namespace: namespace}},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "ingress-nginx-admission-" + namespace}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: utils.INGRESS_NGINX_ADMISSION, Namespace: namespace}},
+	}
+
+	for _, object := range legacy {
+		err := r.Get(ctx, client.ObjectKeyFromObject(object), object)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := object.GetLabels()[utils.TENANT_NAME_LABEL]; ok {
+			// certgen never labelled its objects, this one belongs to a tenant
+			// whose name happens to produce the same cluster-scoped name
+			continue
+		}
+		name := pipeline.ObjectName(r.Client, object)
+		err = r.Delete(ctx, object, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			recordFailed(r.Recorder, ingressDeployment, EventReasonDeleteFailed, fmt.Errorf("%s: %w", name, err))
+			return err
+		}
+		l.Info(fmt.Sprintf("removed certgen leftover %s", name))
+		recordDeleted(r.Recorder, ingressDeployment, name)
+	}
+
+	return nil
+}
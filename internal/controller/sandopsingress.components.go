@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Components of a tenant ingress controller, used in DependsOn and in the
+// Ready condition.
+const (
+	componentNamespace           = "namespace"
+	componentServiceAccount      = "service-account"
+	componentRole                = "role"
+	componentRoleBinding         = "role-binding"
+	componentClusterRole         = "cluster-role"
+	componentClusterRoleBinding  = "cluster-role-binding"
+	componentConfigMap           = "configmap"
+	componentTCPServices         = "tcp-services"
+	componentUDPServices         = "udp-services"
+	componentService             = "service"
+	componentAdmissionService    = "admission-service"
+	componentIngressClass        = "ingress-class"
+	componentAdmissionSecret     = "admission-secret"
+	componentWebhook             = "webhook"
+	componentDeployment          = "deployment"
+	componentErrorPages          = "error-pages"
+	componentErrorBackend        = "error-backend"
+	componentErrorBackendService = "error-backend-service"
+	componentMaintenanceService  = "maintenance-service"
+	componentActivatorService    = "activator-service"
+)
+
+// ingressComponents declares everything a SandOpsIngress runs on. The
+// pipeline applies them in dependency order and deletes them in reverse.
+func (r *SandOpsIngressReconciler) ingressComponents(ingressDeployment *controllerapi.SandOpsIngress) []pipeline.Component {
+	return []pipeline.Component{
+		namespaceComponent(ingressDeployment),
+		serviceAccountComponent(ingressDeployment),
+		roleComponent(ingressDeployment),
+		roleBindingComponent(ingressDeployment),
+		clusterRoleComponent(ingressDeployment),
+		clusterRoleBindingComponent(ingressDeployment),
+		r.configMapComponent(ingressDeployment),
+		portServicesComponent(ingressDeployment, corev1.ProtocolTCP),
+		portServicesComponent(ingressDeployment, corev1.ProtocolUDP),
+		serviceComponent(ingressDeployment),
+		admissionServiceComponent(ingressDeployment),
+		ingressClassComponent(ingressDeployment),
+		r.admissionSecretComponent(ingressDeployment),
+		r.webhookComponent(ingressDeployment),
+		r.deploymentComponent(ingressDeployment),
+		errorPagesComponent(ingressDeployment),
+		errorBackendComponent(ingressDeployment),
+		errorBackendServiceComponent(ingressDeployment),
+		maintenanceServiceComponent(ingressDeployment),
+		r.activatorServiceComponent(ingressDeployment),
+	}
+}
+
+func (r *SandOpsIngressReconciler) ingressGraph(ingressDeployment *controllerapi.SandOpsIngress) (*pipeline.Graph, error) {
+	return pipeline.New(r.ingressComponents(ingressDeployment)...)
+}
+
+// tenantObjectMeta names an object in the tenant namespace.
+func tenantObjectMeta(ingressDeployment *controllerapi.SandOpsIngress, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: utils.NSSuffixedNamespace(ingressDeployment.Name)}
+}
+
+func ingressOwnerReferences(ingressDeployment *controllerapi.SandOpsIngress) []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		{
+			Name:               ingressDeployment.Name,
+			APIVersion:         controllerapi.GroupVersion.String(),
+			Kind:               "SandOpsIngress",
+			UID:                ingressDeployment.UID,
+			Controller:         utils.DataTypePointerRef(true),
+			BlockOwnerDeletion: utils.DataTypePointerRef(false),
+		},
+	}
+}
+
+// setOwnedMeta sets the labels the operator owns on obj, including the ones
+// tying it to its SandOpsIngress. Only objects in the namespace of the
+// SandOpsIngress get an owner reference: the garbage collector rejects a
+// namespaced owner on cluster-scoped objects and on other namespaces, so those
+// are found by label instead and removed by the finalizer.
+func setOwnedMeta(obj client.Object, ingressDeployment *controllerapi.SandOpsIngress, labels map[string]string) {
+	owned := tenantLabels(ingressDeployment)
+	for key, value := range labels {
+		owned[key] = value
+	}
+	obj.SetLabels(owned)
+	if obj.GetNamespace() != "" && obj.GetNamespace() == ingressDeployment.Namespace {
+		obj.SetOwnerReferences(ingressOwnerReferences(ingressDeployment))
+	}
+}
+
+// tenantLabels mark every object generated for a SandOpsIngress.
+func tenantLabels(ingressDeployment *controllerapi.SandOpsIngress) map[string]string {
+	return map[string]string{
+		utils.TENANT_NAME_LABEL:      ingressDeployment.Name,
+		utils.TENANT_NAMESPACE_LABEL: ingressDeployment.Namespace,
+	}
+}
+
+// tenantToIngress maps an object generated for a SandOpsIngress back to it.
+func tenantToIngress(ctx context.Context, obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	name, namespace := labels[utils.TENANT_NAME_LABEL], labels[utils.TENANT_NAMESPACE_LABEL]
+	if name == "" || namespace == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}}
+}
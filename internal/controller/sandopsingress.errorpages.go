@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The error backend is a small nginx serving the error and maintenance pages
+// of a tenant. It runs whether or not the tenant uses custom error pages, so
+// putting a frontend into maintenance never waits for pods to start.
+
+func errorPagesComponent(ingressDeployment *controllerapi.SandOpsIngress) pipeline.Component {
+	return pipeline.Component{
+		Name:      componentErrorPages,
+		DependsOn: []string{componentNamespace},
+		Object: func() client.Object {
+			return &corev1.ConfigMap{ObjectMeta: tenantObjectMeta(ingressDeployment, utils.ERROR_PAGES)}
+		},
+		Desired: func(ctx context.Context, obj client.Object) error {
+			configMap := obj.(*corev1.ConfigMap)
+			setOwnedMeta(configMap, ingressDeployment, utils.IngressLabel(utils.ERROR_BACKEND))
+			configMap.Data = utils.ErrorPagesData(ingressDeployment)
+			return nil
+		},
+	}
+}
+
+func errorBackendComponent(ingressDeployment *controllerapi.SandOpsIngress) pipeline.Component {
+	return pipeline.Component{
+		Name:      componentErrorBackend,
+		DependsOn: []string{componentErrorPages},
+		Object: func() client.Object {
+			return &appsv1.Deployment{ObjectMeta: tenantObjectMeta(ingressDeployment, utils.ERROR_BACKEND)}
+		},
+		Desired: func(ctx context.Context, obj client.Object) error {
+			deployment := obj.(*appsv1.Deployment)
+			setOwnedMeta(deployment, ingressDeployment, utils.IngressLabel(utils.ERROR_BACKEND))
+			deployment.Spec = errorBackendDeploymentSpec(tenantReplicas(ingressDeployment), utils.ErrorPagesHash(utils.ErrorPagesData(ingressDeployment)))
+			return nil
+		},
+	}
+}
+
+// errorBackendServiceComponent is the Service behind the ingress-nginx default
+// backend, which answers the codes of spec.customErrorPages.
+func errorBackendServiceComponent(ingressDeployment *controllerapi.SandOpsIngress) pipeline.Component {
+	return errorPagesServiceComponent(ingressDeployment, componentErrorBackendService, utils.ERROR_BACKEND, utils.ERROR_BACKEND_PORT)
+}
+
+// maintenanceServiceComponent is the Service frontends in maintenance are
+// routed to. It always answers 503.
+func maintenanceServiceComponent(ingressDeployment *controllerapi.SandOpsIngress) pipeline.Component {
+	return errorPagesServiceComponent(ingressDeployment, componentMaintenanceService, utils.MAINTENANCE_BACKEND, utils.MAINTENANCE_BACKEND_PORT)
+}
+
+func errorPagesServiceComponent(ingressDeployment *controllerapi.SandOpsIngress, component, name string, targetPort int) pipeline.Component {
+	return pipeline.Component{
+		Name:      component,
+		DependsOn: []string{componentNamespace},
+		Object: func() client.Object {
+			return &corev1.Service{ObjectMeta: tenantObjectMeta(ingressDeployment, name)}
+		},
+		Desired: func(ctx context.Context, obj client.Object) error {
+			service := obj.(*corev1.Service)
+			setOwnedMeta(service, ingressDeployment, utils.IngressLabel(utils.ERROR_BACKEND))
+			service.Spec.Ports = []corev1.ServicePort{
+				{
+					Name:       "http",
+					Port:       80,
+					Protocol:   corev1.ProtocolTCP,
+					TargetPort: intstr.FromInt(targetPort),
+				},
+			}
+			service.Spec.Selector = errorBackendSelector()
+			service.Spec.Type = corev1.ServiceTypeClusterIP
+			return nil
+		},
+	}
+}
+
+func errorBackendSelector() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/component": utils.ERROR_BACKEND,
+		"app.kubernetes.io/instance":  utils.INGRESS_NGINX,
+		"app.kubernetes.io/name":      utils.INGRESS_NGINX,
+	}
+}
+
+func errorBackendDeploymentSpec(replicas *int32, pagesHash string) appsv1.DeploymentSpec {
+	return appsv1.DeploymentSpec{
+		Replicas:             replicas,
+		RevisionHistoryLimit: utils.DataTypePointerRef(int32(10)),
+		Selector:             &metav1.LabelSelector{MatchLabels: errorBackendSelector()},
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: utils.IngressLabel(utils.ERROR_BACKEND),
+				// nginx reads the pages at startup, a new hash rolls the pods
+				Annotations: map[string]string{utils.ERROR_PAGES_HASH_ANNOTATION: pagesHash},
+			},
+			Spec: corev1.PodSpec{
+				AutomountServiceAccountToken: utils.DataTypePointerRef(false),
+				Containers: []corev1.Container{
+					{
+						Name:            utils.ERROR_BACKEND,
+						Image:           "nginxinc/nginx-unprivileged:1.27-alpine",
+						ImagePullPolicy: corev1.PullIfNotPresent,
+						Ports: []corev1.ContainerPort{
+							{Name: "errors", ContainerPort: utils.ERROR_BACKEND_PORT, Protocol: corev1.ProtocolTCP},
+							{Name: "maintenance", ContainerPort: utils.MAINTENANCE_BACKEND_PORT, Protocol: corev1.ProtocolTCP},
+						},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								HTTPGet: &corev1.HTTPGetAction{
+									Path:   "/healthz",
+									Port:   intstr.FromInt(utils.ERROR_BACKEND_PORT),
+									Scheme: corev1.URISchemeHTTP,
+								},
+							},
+							PeriodSeconds:    10,
+							SuccessThreshold: 1,
+							FailureThreshold: 3,
+							TimeoutSeconds:   1,
+						},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("10m"),
+								corev1.ResourceMemory: resource.MustParse("16Mi"),
+							},
+							Limits: corev1.ResourceList{
+								corev1.ResourceMemory: resource.MustParse("64Mi"),
+							},
+						},
+						SecurityContext: &corev1.SecurityContext{
+							AllowPrivilegeEscalation: utils.DataTypePointerRef(false),
+							Capabilities: &corev1.Capabilities{
+								Drop: []corev1.Capability{"ALL"},
+							},
+							RunAsNonRoot: utils.DataTypePointerRef(true),
+						},
+						VolumeMounts: []corev1.VolumeMount{
+							{
+								MountPath: "/etc/nginx/conf.d",
+								Name:      utils.ERROR_PAGES,
+								ReadOnly:  true,
+							},
+						},
+					},
+				},
+				NodeSelector: map[string]string{
+					"kubernetes.io/os": "linux",
+				},
+				Volumes: []corev1.Volume{
+					{
+						Name: utils.ERROR_PAGES,
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{Name: utils.ERROR_PAGES},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+// updateIngressHibernation works out whether the tenant hibernates at now and
+// records it in the Hibernated condition the deployment components scale by.
+// It returns when the outcome may change next. The frontends of the tenant
+// evaluate the same schedule themselves. An unknown time zone stalls the
+// tenant until the schedule is fixed.
+func (r *SandOpsIngressReconciler) updateIngressHibernation(ctx context.Context, ingressResource *controllerapi.SandOpsIngress, now time.Time) (time.Time, error) {
+	hibernation, err := utils.EvaluateHibernation(ingressResource.Spec.Hibernate, ingressResource.Spec.HibernationSchedule, now)
+	if err != nil {
+		recordFailed(r.Recorder, ingressResource, EventReasonInvalidSpec, err)
+		if statusErr := r.setIngressCondition(ctx, ingressResource, controllerapi.IngressConditionStalled, metav1.ConditionTrue, "InvalidHibernationSchedule", err.Error()); statusErr != nil {
+			return time.Time{}, statusErr
+		}
+		return time.Time{}, terminal("InvalidHibernationSchedule", err)
+	}
+
+	wasHibernated := tenantHibernated(ingressResource)
+	status, message := metav1.ConditionFalse, "the ingress controller runs its replicas"
+	if hibernation.Hibernated {
+		status, message = metav1.ConditionTrue, "the ingress controller and the frontends are scaled to zero"
+	}
+	if err := r.setIngressCondition(ctx, ingressResource, controllerapi.IngressConditionHibernated, status, hibernation.Reason, message); err != nil {
+		return time.Time{}, err
+	}
+	switch {
+	case hibernation.Hibernated && !wasHibernated:
+		r.Recorder.Eventf(ingressResource, corev1.EventTypeNormal, EventReasonHibernated, "scaled to zero: %s", hibernation.Reason)
+	case !hibernation.Hibernated && wasHibernated:
+		r.Recorder.Event(ingressResource, corev1.EventTypeNormal, EventReasonResumed, "scaled back up")
+	}
+	return hibernation.Next, nil
+}
+
+// tenantHibernated reports whether the tenant was last found hibernated.
+func tenantHibernated(ingressResource *controllerapi.SandOpsIngress) bool {
+	return meta.IsStatusConditionTrue(ingressResource.Status.Conditions, controllerapi.IngressConditionHibernated)
+}
+
+// tenantReplicas is the replica count of the tenant's own Deployments.
+func tenantReplicas(ingressResource *controllerapi.SandOpsIngress) *int32 {
+	if tenantHibernated(ingressResource) {
+		return utils.DataTypePointerRef(int32(0))
+	}
+	return utils.DataTypePointerRef(int32(1))
+}
+
+// setIngressCondition records a condition on the SandOpsIngress status and
+// only writes to the API server when the condition actually changed.
+func (r *SandOpsIngressReconciler) setIngressCondition(ctx context.Context, ingressResource *controllerapi.SandOpsIngress, conditionType string, status metav1.ConditionStatus, reason, message string) error {
+	changed := meta.SetStatusCondition(&ingressResource.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: ingressResource.Generation,
+	})
+	if !changed {
+		return nil
+	}
+	return r.Status().Update(ctx, ingressResource)
+}
+
+// removeIngressCondition drops a condition from the SandOpsIngress status,
+// writing only when it was set.
+func (r *SandOpsIngressReconciler) removeIngressCondition(ctx context.Context, ingressResource *controllerapi.SandOpsIngress, conditionType string) error {
+	if !meta.RemoveStatusCondition(&ingressResource.Status.Conditions, conditionType) {
+		return nil
+	}
+	return r.Status().Update(ctx, ingressResource)
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Earlier versions of the operator bound every tenant to one ClusterRole named
+// ingress-nginx, which each tenant overwrote and deleted when it went away.
+// Every tenant now has a ClusterRole of its own, named by
+// utils.ClusterScopedName.
+
+// migrateClusterRoleBinding deletes the tenant ClusterRoleBinding when it still
+// refers to the shared ClusterRole. The role of a binding can't be changed, so
+// the binding is applied again from scratch.
+func (r *SandOpsIngressReconciler) migrateClusterRoleBinding(ctx context.Context, ingressDeployment *controllerapi.SandOpsIngress, l logr.Logger) error {
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{}
+	err := r.Get(ctx, client.ObjectKey{Name: utils.ClusterScopedName(utils.INGRESS_NGINX, ingressDeployment.Name)}, clusterRoleBinding)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if clusterRoleBinding.RoleRef == tenantClusterRoleRef(ingressDeployment) {
+		return nil
+	}
+
+	if err := r.Delete(ctx, clusterRoleBinding); err != nil && !errors.IsNotFound(err) {
+		recordFailed(r.Recorder, ingressDeployment, EventReasonDeleteFailed, err)
+		return err
+	}
+	l.Info(fmt.Sprintf("deleted cluster role binding %s bound to %s", clusterRoleBinding.Name, clusterRoleBinding.RoleRef.Name))
+	recordDeleted(r.Recorder, ingressDeployment, "ClusterRoleBinding/"+clusterRoleBinding.Name)
+	return nil
+}
+
+// removeSharedClusterRole deletes the shared ClusterRole once no binding refers
+// to it anymore. While a binding still does, the role is handed over to the
+// tenant of that binding, so sweeping the objects of the tenant that labelled
+// it doesn't take it away from the others. A ClusterRole of that name the
+// operator didn't create, such as the one of a cluster-wide ingress-nginx
+// install, is left alone.
+func (r *SandOpsIngressReconciler) removeSharedClusterRole(ctx context.Context, ingressDeployment *controllerapi.SandOpsIngress, l logr.Logger) error {
+	clusterRole := &rbacv1.ClusterRole{}
+	err := r.Get(ctx, client.ObjectKey{Name: utils.INGRESS_NGINX}, clusterRole)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !createdByOperator(clusterRole) {
+		return nil
+	}
+
+	clusterRoleBindings := &rbacv1.ClusterRoleBindingList{}
+	if err := r.List(ctx, clusterRoleBindings); err != nil {
+		return err
+	}
+	for _, clusterRoleBinding := range clusterRoleBindings.Items {
+		if clusterRoleBinding.RoleRef.Kind == "ClusterRole" && clusterRoleBinding.RoleRef.Name == clusterRole.Name {
+			// a tenant that hasn't been migrated yet
+			return r.handOverSharedClusterRole(ctx, clusterRole, &clusterRoleBinding)
+		}
+	}
+
+	err = r.Delete(ctx, clusterRole, client.Preconditions{UID: &clusterRole.UID})
+	if err != nil && !errors.IsNotFound(err) {
+		recordFailed(r.Recorder, ingressDeployment, EventReasonDeleteFailed, err)
+		return err
+	}
+	l.Info(fmt.Sprintf("removed shared cluster role %s", clusterRole.Name))
+	recordDeleted(r.Recorder, ingressDeployment, "ClusterRole/"+clusterRole.Name)
+	return nil
+}
+
+func (r *SandOpsIngressReconciler) handOverSharedClusterRole(ctx context.Context, clusterRole *rbacv1.ClusterRole, clusterRoleBinding *rbacv1.ClusterRoleBinding) error {
+	labels := clusterRole.GetLabels()
+	changed := false
+	for _, key := range []string{utils.TENANT_NAME_LABEL, utils.TENANT_NAMESPACE_LABEL} {
+		value, ok := clusterRoleBinding.Labels[key]
+		if current, exists := labels[key]; exists == ok && current == value {
+			continue
+		}
+		changed = true
+		if !ok {
+			delete(labels, key)
+			continue
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[key] = value
+	}
+	if !changed {
+		return nil
+	}
+	clusterRole.SetLabels(labels)
+	return r.Update(ctx, clusterRole)
+}
+
+// createdByOperator reports whether obj carries the marks the operator leaves
+// on tenant objects, now or in earlier versions.
+func createdByOperator(obj metav1.Object) bool {
+	if _, ok := obj.GetLabels()[utils.TENANT_NAME_LABEL]; ok {
+		return true
+	}
+	for _, ownerReference := range obj.GetOwnerReferences() {
+		if ownerReference.APIVersion == controllerapi.GroupVersion.String() && ownerReference.Kind == "SandOpsIngress" {
+			return true
+		}
+	}
+	return false
+}
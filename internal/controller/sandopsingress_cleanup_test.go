@@ -0,0 +1,242 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("SandOpsIngress cleanup", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		reconciler *SandOpsIngressReconciler
+		ingress    *aasdevv1.SandOpsIngress
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		ingress = &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(ingress).
+			WithStatusSubresource(&aasdevv1.SandOpsIngress{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		reconciler = &SandOpsIngressReconciler{
+			Client:   c,
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(100),
+		}
+	})
+
+	// tenantObjects lists every object labelled for the tenant, whatever its
+	// kind or namespace.
+	tenantObjects := func() []string {
+		var names []string
+		listed := map[string]bool{}
+		for _, component := range reconciler.ingressComponents(ingress) {
+			gvk, err := apiutil.GVKForObject(component.Object(), c.Scheme())
+			Expect(err).NotTo(HaveOccurred())
+			if listed[gvk.Kind] {
+				continue
+			}
+			listed[gvk.Kind] = true
+
+			list := &metav1.PartialObjectMetadataList{}
+			list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+			Expect(c.List(ctx, list, client.MatchingLabels(tenantLabels(ingress)))).To(Succeed())
+			for _, item := range list.Items {
+				names = append(names, gvk.Kind+"/"+item.Namespace+"/"+item.Name)
+			}
+		}
+		return names
+	}
+
+	It("leaves no cluster-scoped or namespaced objects behind", func() {
+		request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)}
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tenantObjects()).To(ContainElements(
+			"Namespace//tenant-ns",
+			"ClusterRoleBinding//ingress-nginx-tenant-ns",
+			"IngressClass//nginx-tenant-ns",
+			"ValidatingWebhookConfiguration//ingress-nginx-admission-tenant-ns",
+			"Deployment/tenant-ns/ingress-nginx-controller",
+		))
+
+		// an object of a component the operator no longer declares
+		leftover := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "ingress-nginx-renamed", Labels: tenantLabels(ingress)}}
+		Expect(c.Create(ctx, leftover)).To(Succeed())
+
+		Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+		Expect(ingress.Finalizers).To(ContainElement(utils.INGRESS_FINALIZER))
+		Expect(c.Delete(ctx, ingress)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(tenantObjects()).To(BeEmpty())
+		err = c.Get(ctx, request.NamespacedName, &aasdevv1.SandOpsIngress{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("records an Event for every object it creates and deletes", func() {
+		recorder := reconciler.Recorder.(*record.FakeRecorder)
+		events := func() []string {
+			var recorded []string
+			for len(recorder.Events) > 0 {
+				recorded = append(recorded, <-recorder.Events)
+			}
+			return recorded
+		}
+
+		request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)}
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events()).To(ContainElements(
+			"Normal Created created Namespace/tenant-ns",
+			"Normal Created created Deployment/tenant-ns/ingress-nginx-controller",
+		))
+
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events()).To(BeEmpty())
+
+		Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+		Expect(c.Delete(ctx, ingress)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events()).To(ContainElements(
+			"Normal Deleted deleted Deployment/tenant-ns/ingress-nginx-controller",
+			"Normal Deleted deleted ClusterRoleBinding/ingress-nginx-tenant-ns",
+		))
+	})
+
+	It("only sets owner references within the namespace of the SandOpsIngress", func() {
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)})
+		Expect(err).NotTo(HaveOccurred())
+
+		clusterRoleBinding := &rbacv1.ClusterRoleBinding{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "ingress-nginx-tenant-ns"}, clusterRoleBinding)).To(Succeed())
+		Expect(clusterRoleBinding.OwnerReferences).To(BeEmpty())
+		Expect(clusterRoleBinding.Labels).To(HaveKeyWithValue(utils.TENANT_NAME_LABEL, "tenant"))
+
+		role := &rbacv1.Role{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: utils.INGRESS_NGINX, Namespace: "tenant-ns"}, role)).To(Succeed())
+		Expect(role.OwnerReferences).To(HaveLen(1))
+	})
+
+	Describe("moving tenants off the shared ClusterRole", func() {
+		var request reconcile.Request
+
+		sharedClusterRole := func(labels map[string]string) *rbacv1.ClusterRole {
+			return &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: utils.INGRESS_NGINX, Labels: labels}}
+		}
+		sharedBinding := func(name string, labels map[string]string) *rbacv1.ClusterRoleBinding {
+			return &rbacv1.ClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: utils.INGRESS_NGINX},
+			}
+		}
+		otherLabels := map[string]string{utils.TENANT_NAME_LABEL: "other", utils.TENANT_NAMESPACE_LABEL: "other-ns"}
+
+		BeforeEach(func() {
+			request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)}
+			Expect(c.Create(ctx, sharedBinding("ingress-nginx-tenant-ns", tenantLabels(ingress)))).To(Succeed())
+		})
+
+		It("binds the tenant to a ClusterRole of its own and removes the shared one", func() {
+			Expect(c.Create(ctx, sharedClusterRole(tenantLabels(ingress)))).To(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			clusterRoleBinding := &rbacv1.ClusterRoleBinding{}
+			Expect(c.Get(ctx, client.ObjectKey{Name: "ingress-nginx-tenant-ns"}, clusterRoleBinding)).To(Succeed())
+			Expect(clusterRoleBinding.RoleRef.Name).To(Equal("ingress-nginx-tenant-ns"))
+			Expect(c.Get(ctx, client.ObjectKey{Name: "ingress-nginx-tenant-ns"}, &rbacv1.ClusterRole{})).To(Succeed())
+			err = c.Get(ctx, client.ObjectKey{Name: utils.INGRESS_NGINX}, &rbacv1.ClusterRole{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("keeps the shared ClusterRole while another tenant is bound to it", func() {
+			Expect(c.Create(ctx, sharedClusterRole(tenantLabels(ingress)))).To(Succeed())
+			Expect(c.Create(ctx, sharedBinding("ingress-nginx-other-ns", otherLabels))).To(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+			Expect(c.Delete(ctx, ingress)).To(Succeed())
+			_, err = reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			clusterRole := &rbacv1.ClusterRole{}
+			Expect(c.Get(ctx, client.ObjectKey{Name: utils.INGRESS_NGINX}, clusterRole)).To(Succeed())
+			Expect(clusterRole.Labels).To(Equal(otherLabels))
+		})
+
+		It("leaves a ClusterRole of the same name it didn't create alone", func() {
+			Expect(c.Create(ctx, sharedClusterRole(nil))).To(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(c.Get(ctx, client.ObjectKey{Name: utils.INGRESS_NGINX}, &rbacv1.ClusterRole{})).To(Succeed())
+		})
+	})
+	Describe("removing kube-webhook-certgen leftovers", func() {
+		It("deletes the Jobs of the tenant and leaves the ingress-nginx-admission ClusterRole alone", func() {
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "ingress-nginx-admission-create", Namespace: "tenant-ns"}}
+			Expect(c.Create(ctx, job)).To(Succeed())
+			// the ClusterRole of an upstream ingress-nginx install
+			foreign := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{
+				Name:   utils.INGRESS_NGINX_ADMISSION,
+				Labels: map[string]string{"app.kubernetes.io/name": "ingress-nginx", "app.kubernetes.io/component": "admission-webhook"},
+			}}
+			Expect(c.Create(ctx, foreign)).To(Succeed())
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = c.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(foreign), &rbacv1.ClusterRole{})).To(Succeed())
+		})
+	})
+})
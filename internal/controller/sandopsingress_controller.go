@@ -19,23 +19,33 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
-	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	pkgcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 
 	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/metrics"
+	"sandtech.io/sand-ops/internal/pipeline"
+	"sandtech.io/sand-ops/internal/tracing"
 	"sandtech.io/sand-ops/internal/utils"
 )
 
@@ -45,11 +55,26 @@ type SandOpsIngressReconciler struct {
 	Scheme *runtime.Scheme
 	Log    logr.Logger
 	KubeClients
+	Recorder    record.EventRecorder
+	RateLimiter RateLimiterOptions
+	// ActivatorService is the host of the operator's activator Service, which
+	// the activator Service of every tenant points at. Defaults to
+	// utils.DEFAULT_ACTIVATOR_SERVICE.
+	ActivatorService string
+	// SnippetValidation is set when the operator serves the webhook that
+	// checks snippet annotations against the allowed directives. Without it
+	// snippets stay disabled whatever spec.snippets says.
+	SnippetValidation bool
 }
 
+// driftResyncPeriod bounds how long drift on objects that don't trigger a
+// reconcile, such as cluster-scoped ones, goes unnoticed.
+const driftResyncPeriod = 10 * time.Minute
+
 // +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=sandopsingresses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=sandopsingresses/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=aasdev.sandtech.io,resources=sandopsingresses/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -61,6 +86,13 @@ type SandOpsIngressReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.18.4/pkg/reconcile
 func (r *SandOpsIngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracing.StartReconcile(ctx, "SandOpsIngress", req.NamespacedName)
+	result, err := r.reconcile(ctx, req)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (r *SandOpsIngressReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	l := r.Log.WithValues("reconciling:", req.NamespacedName)
 
 	ingressResource := &controllerapi.SandOpsIngress{}
@@ -83,215 +115,216 @@ func (r *SandOpsIngressReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{}, nil
 	}
 
-	ingressNamespaceResource, err := r.reconcileNamespace(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, fmt.Sprintf("failed to create namespace for ingress controller: %s/%s", ingressNamespaceResource.Name, ingressNamespaceResource.Namespace))
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created namespace for ingress controller: %s/%s", ingressNamespaceResource.Name, ingressNamespaceResource.Namespace))
+	if ingressResource.Spec.Suspend {
+		l.Info("tenant is suspended, skipping reconcile")
+		return ctrl.Result{}, r.setIngressCondition(ctx, ingressResource, controllerapi.IngressConditionSuspended, metav1.ConditionTrue, "Suspended", "spec.suspend is set")
 	}
-
-	serviceAccountAdmission, err := r.reconcileServiceAccountAdmission(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, fmt.Sprintf("failed to create ingress admission service account: %s/%s", serviceAccountAdmission.Name, serviceAccountAdmission.Namespace))
-			return ctrl.Result{}, nil
-		}
+	if err := r.removeIngressCondition(ctx, ingressResource, controllerapi.IngressConditionSuspended); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	ingressAdmissionRole, err := r.reconcileIngressAdmissionRole(ctx, ingressResource, l)
+	now := time.Now()
+	nextHibernationChange, err := r.updateIngressHibernation(ctx, ingressResource, now)
 	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress admission role")
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress admission role: %s/%s", ingressAdmissionRole.Name, ingressAdmissionRole.Namespace))
+		l.Error(err, "failed to work out hibernation")
+		return ctrl.Result{}, reconcileError(err)
 	}
 
-	ingressAdmissionRoleBinding, err := r.reconcileIngressAdmissionRoleBinding(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress role binding")
-			return ctrl.Result{}, nil
+	if reason, err := validateIngressSpec(ingressResource); err != nil {
+		l.Error(err, "invalid ingress spec")
+		recordFailed(r.Recorder, ingressResource, EventReasonInvalidSpec, err)
+		if statusErr := r.setIngressCondition(ctx, ingressResource, controllerapi.IngressConditionStalled, metav1.ConditionTrue, reason, err.Error()); statusErr != nil {
+			return ctrl.Result{}, statusErr
 		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress role binding: %s/%s", ingressAdmissionRoleBinding.Name, ingressAdmissionRoleBinding.Namespace))
+		return ctrl.Result{}, reconcileError(terminal(reason, err))
 	}
-
-	admissionClusterRole, err := r.reconcileAdmissionClusterRole(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create admission cluster role")
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created admission cluster role: %s/%s", admissionClusterRole.Name, admissionClusterRole.Namespace))
+	if utils.SnippetsEnabled(ingressResource) && !r.SnippetValidation {
+		r.Recorder.Event(ingressResource, corev1.EventTypeWarning, EventReasonSnippetsDisabled, "snippets stay disabled, the operator doesn't serve the webhook that checks them")
 	}
 
-	ingressAdmissionClusterRoleBinding, err := r.reconcileIngressAdmissionClusterRoleBinding(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress admission cluster role binding")
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress admission cluster role binding: %s/%s", ingressAdmissionClusterRoleBinding.Name, ingressAdmissionClusterRoleBinding.Namespace))
+	if err := r.removeCertgenResources(ctx, ingressResource, l); err != nil {
+		l.Error(err, "failed to remove certgen resources")
+		return ctrl.Result{}, err
 	}
 
-	ingressWebhook, err := r.reconcileIngressWebhook(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress webhook")
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress webhook: %s/%s", ingressWebhook.Name, ingressWebhook.Namespace))
+	if err := r.migrateClusterRoleBinding(ctx, ingressResource, l); err != nil {
+		l.Error(err, "failed to migrate cluster role binding")
+		return ctrl.Result{}, err
 	}
 
-	jobAdmissionCreate, err := r.reconcileJobAdmissionCreate(ctx, ingressResource, l)
+	graph, err := r.ingressGraph(ingressResource)
 	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress job admission")
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress job admission: %s/%s", jobAdmissionCreate.Name, jobAdmissionCreate.Namespace))
+		return ctrl.Result{}, reconcile.TerminalError(err)
 	}
-
-	jobAdmissionPatchCreate, err := r.reconcileJobPatchAdmissionCreate(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress job admission")
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress job admission: %s/%s", jobAdmissionPatchCreate.Name, jobAdmissionPatchCreate.Namespace))
+	reportOnly := ingressResource.Spec.DriftPolicy == controllerapi.DriftPolicyReportOnly
+	results, applyErr := graph.Apply(ctx, r.Client, l, pipeline.ApplyOptions{ReportOnly: reportOnly})
+	if applyErr != nil {
+		l.Error(applyErr, "failed to reconcile ingress components")
 	}
+	drift := r.recordApplyResults(ingressResource, results)
 
-	serviceAccountIngress, err := r.reconcileServiceAccountIngress(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, fmt.Sprintf("failed to create ingress service account: %s/%s", serviceAccountIngress.Name, serviceAccountIngress.Namespace))
-			return ctrl.Result{}, nil
-		}
+	if err := r.updateIngressStatus(ctx, ingressResource, results, drift); err != nil {
+		l.Error(err, "failed to update ingress status")
+		return ctrl.Result{}, err
 	}
-
-	ingressRole, err := r.reconcileIngressRole(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress role")
-			return ctrl.Result{}, nil
+	if applyErr != nil {
+		if len(terminalFailures(results)) == len(failures(results)) {
+			return ctrl.Result{}, reconcile.TerminalError(applyErr)
 		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress role: %s/%s", ingressRole.Name, ingressRole.Namespace))
+		return ctrl.Result{}, applyErr
 	}
-
-	ingressRoleBinding, err := r.reconcileIngressRoleBinding(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress role binding")
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress role binding: %s/%s", ingressRoleBinding.Name, ingressRoleBinding.Namespace))
+	if err := r.removeSharedClusterRole(ctx, ingressResource, l); err != nil {
+		l.Error(err, "failed to remove shared cluster role")
+		return ctrl.Result{}, err
 	}
 
-	ingressClusterRole, err := r.reconcileClusterRole(ctx, ingressResource, l)
+	requeueAfter, err := r.admissionCertRequeue(ctx, ingressResource)
 	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create cluster role")
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress cluster role: %s/%s", ingressClusterRole.Name, ingressClusterRole.Namespace))
+		l.Error(err, "failed to read ingress admission certificate")
+		return ctrl.Result{}, err
 	}
-
-	ingressClusterRoleBinding, err := r.reconcileIngressClusterRoleBinding(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress cluster role binding")
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress cluster role binding: %s/%s", ingressClusterRoleBinding.Name, ingressClusterRoleBinding.Namespace))
+	if requeueAfter <= 0 || requeueAfter > driftResyncPeriod {
+		requeueAfter = driftResyncPeriod
 	}
+	if hibernationChange := requeueAt(nextHibernationChange, now); hibernationChange > 0 && hibernationChange < requeueAfter {
+		requeueAfter = hibernationChange
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
 
-	ingressConfigMap, err := r.reconcileConfigMap(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress configmap")
-			return ctrl.Result{}, nil
-		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress configmap: %s/%s", ingressConfigMap.Name, ingressConfigMap.Namespace))
+// validateIngressSpec checks what the CRD schema can't, returning the reason
+// of the Stalled condition when the spec is invalid.
+func validateIngressSpec(ingressResource *controllerapi.SandOpsIngress) (string, error) {
+	if err := utils.ValidateConfigMapSnippets(ingressResource); err != nil {
+		return "InvalidNginxConfig", err
 	}
+	if err := utils.ValidatePortServices(ingressResource); err != nil {
+		return "InvalidPortService", err
+	}
+	return "", nil
+}
 
-	ingressService, err := r.reconcileService(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress service")
-			return ctrl.Result{}, nil
+// snippetsEnabled reports whether the tenant ingress controller accepts
+// snippets, which needs the webhook that checks them.
+func (r *SandOpsIngressReconciler) snippetsEnabled(ingressResource *controllerapi.SandOpsIngress) bool {
+	return r.SnippetValidation && utils.SnippetsEnabled(ingressResource)
+}
+
+// recordApplyResults turns the applied components into Events and metrics, and
+// returns the drift to list in the status.
+func (r *SandOpsIngressReconciler) recordApplyResults(ingressResource *controllerapi.SandOpsIngress, results []pipeline.Result) []controllerapi.ComponentDrift {
+	recordResults(r.Recorder, ingressResource, results, EventReasonApplyFailed)
+
+	tenant := client.ObjectKeyFromObject(ingressResource).String()
+	var drift []controllerapi.ComponentDrift
+	for _, result := range results {
+		if result.Action != pipeline.ActionBlocked {
+			metrics.ObserveStep(metrics.ControllerSandOpsIngress, result.Component, result.Duration, result.Err)
+		}
+		switch result.Action {
+		case pipeline.ActionRepaired:
+			metrics.DriftCorrections.WithLabelValues(tenant, result.Component).Inc()
+			r.Recorder.Eventf(ingressResource, corev1.EventTypeNormal, EventReasonDriftRepaired, "reverted changes to %s: %s", result.Object, strings.Join(result.Drift, ", "))
+		case pipeline.ActionDrifted:
+			r.Recorder.Eventf(ingressResource, corev1.EventTypeWarning, EventReasonDriftDetected, "%s was changed outside the operator: %s", result.Object, strings.Join(result.Drift, ", "))
+			drift = append(drift, controllerapi.ComponentDrift{
+				Component: result.Component,
+				Object:    result.Object,
+				Fields:    result.Drift,
+			})
 		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress service: %s/%s", ingressService.Name, ingressService.Namespace))
 	}
+	metrics.DriftedComponents.WithLabelValues(tenant).Set(float64(len(drift)))
+	return drift
+}
 
-	ingressAdmissionService, err := r.reconcileServiceAdmission(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress admission service")
-			return ctrl.Result{}, nil
+// updateIngressStatus records the Ready and Stalled conditions and the drift
+// report on the SandOpsIngress status and only writes to the API server when
+// any of them changed.
+func (r *SandOpsIngressReconciler) updateIngressStatus(ctx context.Context, ingressResource *controllerapi.SandOpsIngress, results []pipeline.Result, drift []controllerapi.ComponentDrift) error {
+	changed := meta.SetStatusCondition(&ingressResource.Status.Conditions, pipeline.ReadyCondition(controllerapi.IngressConditionReady, results, ingressResource.Generation))
+	if stalled := terminalFailures(results); len(stalled) > 0 {
+		reason, _ := terminalReason(stalled[0].Err)
+		messages := make([]string, 0, len(stalled))
+		for _, result := range stalled {
+			messages = append(messages, result.Err.Error())
 		}
+		changed = meta.SetStatusCondition(&ingressResource.Status.Conditions, metav1.Condition{
+			Type:               controllerapi.IngressConditionStalled,
+			Status:             metav1.ConditionTrue,
+			Reason:             reason,
+			Message:            strings.Join(messages, "; "),
+			ObservedGeneration: ingressResource.Generation,
+		}) || changed
 	} else {
-		l.Info(fmt.Sprintf("successfully created ingress admission service: %s/%s", ingressAdmissionService.Name, ingressAdmissionService.Namespace))
+		changed = meta.RemoveStatusCondition(&ingressResource.Status.Conditions, controllerapi.IngressConditionStalled) || changed
 	}
+	if !equality.Semantic.DeepEqual(ingressResource.Status.Drift, drift) {
+		ingressResource.Status.Drift = drift
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return r.Status().Update(ctx, ingressResource)
+}
 
-	ingressClass, err := r.reconcileIngressClass(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress class")
-			return ctrl.Result{}, nil
+func failures(results []pipeline.Result) []pipeline.Result {
+	var failed []pipeline.Result
+	for _, result := range results {
+		if result.Action == pipeline.ActionFailed {
+			failed = append(failed, result)
 		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress class: %s/%s", ingressClass.Name, ingressClass.Namespace))
 	}
+	return failed
+}
 
-	ingressDeployment, err := r.reconcileIngressControllerDeployment(ctx, ingressResource, l)
-	if err != nil {
-		if err.Error() != utils.FOUND {
-			l.Error(err, "failed to create ingress deployment")
-			return ctrl.Result{}, nil
+// terminalFailures lists the components that failed in a way retrying can't
+// fix. Components blocked by them are not listed.
+func terminalFailures(results []pipeline.Result) []pipeline.Result {
+	var terminal []pipeline.Result
+	for _, result := range failures(results) {
+		if _, ok := terminalReason(result.Err); ok {
+			terminal = append(terminal, result)
 		}
-	} else {
-		l.Info(fmt.Sprintf("successfully created ingress deployment: %s/%s", ingressDeployment.Name, ingressDeployment.Namespace))
 	}
-
-	return ctrl.Result{}, nil
+	return terminal
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SandOpsIngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&controllerapi.SandOpsIngress{}).
-		WithOptions(pkgcontroller.Options{MaxConcurrentReconciles: 2}).
-		Owns(&corev1.Namespace{}).
-		Owns(&corev1.ServiceAccount{}).
-		Owns(&rbacv1.ClusterRole{}).
-		Owns(&rbacv1.ClusterRoleBinding{}).
-		Owns(&corev1.ServiceAccount{}).
-		Owns(&rbacv1.Role{}).
-		Owns(&rbacv1.RoleBinding{}).
-		Owns(&corev1.ConfigMap{}).
-		Owns(&corev1.Service{}).
-		Owns(&appsv1.Deployment{}).
-		Owns(&batchv1.Job{}).
-		Owns(&corev1.Secret{}).
-		Owns(&networkingv1.IngressClass{}).
-		Owns(&admissionregistrationv1.ValidatingWebhookConfiguration{}).
-		Owns(&networkingv1.NetworkPolicy{}).
-		Owns(&networkingv1.Ingress{}).
+		WithOptions(pkgcontroller.Options{MaxConcurrentReconciles: 2, RateLimiter: NewRateLimiter(r.RateLimiter)})
+
+	// generated objects are matched by label, owner references can't point
+	// from cluster-scoped objects or other namespaces to a SandOpsIngress
+	for _, obj := range []client.Object{
+		&corev1.Namespace{},
+		&corev1.ServiceAccount{},
+		&rbacv1.ClusterRole{},
+		&rbacv1.ClusterRoleBinding{},
+		&rbacv1.Role{},
+		&rbacv1.RoleBinding{},
+		&corev1.ConfigMap{},
+		&corev1.Service{},
+		&appsv1.Deployment{},
+		&corev1.Secret{},
+		&networkingv1.IngressClass{},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{},
+		&networkingv1.NetworkPolicy{},
+		&networkingv1.Ingress{},
+	} {
+		builder = builder.Watches(obj, handler.EnqueueRequestsFromMapFunc(tenantToIngress))
+	}
+
+	return builder.
+		Watches(&controllerapi.FrontendDeploy{}, handler.EnqueueRequestsFromMapFunc(r.frontendToIngress)).
 		Complete(r)
 }
+
+// frontendToIngress maps a FrontendDeploy to the SandOpsIngress of its tenant,
+// whose ConfigMap lists the response headers the frontends use.
+func (r *SandOpsIngressReconciler) frontendToIngress(ctx context.Context, obj client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: utils.IngressNamespacedName(obj.GetNamespace())}}
+}
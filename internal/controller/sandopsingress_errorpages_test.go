@@ -0,0 +1,63 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("SandOpsIngress error pages", func() {
+	var ingress *aasdevv1.SandOpsIngress
+
+	BeforeEach(func() {
+		ingress = &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+	})
+
+	It("leaves the ingress controller defaults alone without custom error pages", func() {
+		Expect(ingressConfigMapData(ingress, false, nil)).NotTo(HaveKey("custom-http-errors"))
+		Expect(ingressControllerArgs(ingress)).NotTo(ContainElement(HavePrefix("--default-backend-service=")))
+
+		data := utils.ErrorPagesData(ingress)
+		Expect(data).To(HaveKeyWithValue(utils.ErrorPageKey, utils.DefaultErrorPageHTML))
+		Expect(data).To(HaveKeyWithValue(utils.MaintenancePageKey, utils.DefaultMaintenancePageHTML))
+	})
+
+	It("sends the listed codes to the error backend of the tenant", func() {
+		ingress.Spec.CustomErrorPages = &aasdevv1.CustomErrorPages{Codes: []int32{503, 502, 503}, Page: "<h1>oops</h1>"}
+
+		Expect(ingressConfigMapData(ingress, false, nil)).To(HaveKeyWithValue("custom-http-errors", "502,503"))
+		Expect(ingressControllerArgs(ingress)).To(ContainElement("--default-backend-service=tenant-ns/" + utils.ERROR_BACKEND))
+
+		data := utils.ErrorPagesData(ingress)
+		Expect(data).To(HaveKeyWithValue(utils.ErrorPageKey, "<h1>oops</h1>"))
+		for _, code := range []string{"404", "502", "503"} {
+			Expect(data[utils.ErrorPagesConfigKey]).To(ContainSubstring("error_page " + code + " /error.html;"))
+			Expect(data[utils.ErrorPagesConfigKey]).To(ContainSubstring("if ($http_x_code = " + code + ")"))
+		}
+	})
+
+	It("rolls the error backend when a page changes", func() {
+		before := utils.ErrorPagesHash(utils.ErrorPagesData(ingress))
+		ingress.Spec.MaintenancePage = "<h1>back soon</h1>"
+		Expect(utils.ErrorPagesHash(utils.ErrorPagesData(ingress))).NotTo(Equal(before))
+	})
+})
@@ -0,0 +1,120 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("SandOpsIngress suspend and hibernation", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		reconciler *SandOpsIngressReconciler
+		ingress    *aasdevv1.SandOpsIngress
+		request    reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		ingress = &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+		request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)}
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(ingress).
+			WithStatusSubresource(&aasdevv1.SandOpsIngress{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		reconciler = &SandOpsIngressReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+	})
+
+	update := func(change func()) {
+		Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+		change()
+		Expect(c.Update(ctx, ingress)).To(Succeed())
+	}
+	replicas := func(name string) int32 {
+		deployment := &appsv1.Deployment{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: name, Namespace: "tenant-ns"}, deployment)).To(Succeed())
+		return *deployment.Spec.Replicas
+	}
+
+	It("leaves a suspended tenant alone", func() {
+		update(func() { ingress.Spec.Suspend = true })
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		err = c.Get(ctx, client.ObjectKey{Name: utils.INGRESS_NGINX_CONTROLLER, Namespace: "tenant-ns"}, &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+		Expect(meta.IsStatusConditionTrue(ingress.Status.Conditions, aasdevv1.IngressConditionSuspended)).To(BeTrue())
+	})
+
+	It("scales the ingress controller and the error backend to zero while hibernating", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas(utils.INGRESS_NGINX_CONTROLLER)).To(Equal(int32(1)))
+
+		update(func() { ingress.Spec.Hibernate = true })
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas(utils.INGRESS_NGINX_CONTROLLER)).To(BeZero())
+		Expect(replicas(utils.ERROR_BACKEND)).To(BeZero())
+		Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+		Expect(meta.IsStatusConditionTrue(ingress.Status.Conditions, aasdevv1.IngressConditionHibernated)).To(BeTrue())
+
+		update(func() { ingress.Spec.Hibernate = false })
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas(utils.INGRESS_NGINX_CONTROLLER)).To(Equal(int32(1)))
+	})
+
+	It("stalls on a schedule in an unknown time zone", func() {
+		update(func() {
+			ingress.Spec.HibernationSchedule = &aasdevv1.HibernationSchedule{TimeZone: "Mars/Olympus", Windows: []aasdevv1.HibernationWindow{
+				{Days: []string{"Sat"}, Start: "00:00", End: "00:00"},
+			}}
+		})
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(HaveOccurred())
+		Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+		stalled := meta.FindStatusCondition(ingress.Status.Conditions, aasdevv1.IngressConditionStalled)
+		Expect(stalled).NotTo(BeNil())
+		Expect(stalled.Reason).To(Equal("InvalidHibernationSchedule"))
+	})
+})
@@ -0,0 +1,113 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("SandOpsIngress nginx config", func() {
+	It("lets typed settings win over spec.nginxConfig", func() {
+		gzip := true
+		ingress := &aasdevv1.SandOpsIngress{Spec: aasdevv1.SandOpsIngressSpec{
+			NginxConfig:   map[string]string{"use-gzip": "false", "proxy-body-size": "8m"},
+			NginxSettings: &aasdevv1.NginxSettings{Gzip: &gzip},
+		}}
+		data := ingressConfigMapData(ingress, false, nil)
+		Expect(data).To(HaveKeyWithValue("use-gzip", "true"))
+		Expect(data).To(HaveKeyWithValue("proxy-body-size", "8m"))
+		Expect(data).To(HaveKeyWithValue("allow-snippet-annotations", "false"))
+	})
+
+	It("drops the snippet keys unless snippets are enabled", func() {
+		ingress := &aasdevv1.SandOpsIngress{Spec: aasdevv1.SandOpsIngressSpec{
+			NginxConfig: map[string]string{
+				"http-snippet":        "expires 1h;",
+				"server-snippet":      "expires 1h;",
+				"global-auth-snippet": "expires 1h;",
+				"modsecurity-snippet": "SecRuleEngine Off",
+			},
+		}}
+		data := ingressConfigMapData(ingress, false, nil)
+		for _, key := range []string{"http-snippet", "server-snippet", "global-auth-snippet", "modsecurity-snippet"} {
+			Expect(data).NotTo(HaveKey(key))
+		}
+
+		Expect(ingressConfigMapData(ingress, true, nil)).To(HaveKeyWithValue("http-snippet", "expires 1h;"))
+		Expect(ingressConfigMapData(ingress, true, nil)).To(HaveKeyWithValue("allow-snippet-annotations", "true"))
+	})
+
+	It("only enables snippets when the snippet webhook is served", func() {
+		ingress := &aasdevv1.SandOpsIngress{Spec: aasdevv1.SandOpsIngressSpec{
+			Snippets: &aasdevv1.SnippetPolicy{Enabled: true},
+		}}
+		Expect((&SandOpsIngressReconciler{}).snippetsEnabled(ingress)).To(BeFalse())
+		Expect((&SandOpsIngressReconciler{SnippetValidation: true}).snippetsEnabled(ingress)).To(BeTrue())
+
+		ingress.Spec.Snippets.Enabled = false
+		Expect((&SandOpsIngressReconciler{SnippetValidation: true}).snippetsEnabled(ingress)).To(BeFalse())
+	})
+
+	It("stalls a tenant whose snippet keys use a directive outside the allow list", func() {
+		ctx := context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+		ingress := &aasdevv1.SandOpsIngress{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"},
+			Spec: aasdevv1.SandOpsIngressSpec{
+				Snippets:    &aasdevv1.SnippetPolicy{Enabled: true},
+				NginxConfig: map[string]string{"main-snippet": "load_module /tmp/module.so;"},
+			},
+		}
+		c := fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(ingress).
+			WithStatusSubresource(&aasdevv1.SandOpsIngress{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		reconciler := &SandOpsIngressReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)})
+		Expect(err).To(HaveOccurred())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(ingress), ingress)).To(Succeed())
+		stalled := meta.FindStatusCondition(ingress.Status.Conditions, aasdevv1.IngressConditionStalled)
+		Expect(stalled).NotTo(BeNil())
+		Expect(stalled.Reason).To(Equal("InvalidNginxConfig"))
+		Expect(stalled.Message).To(ContainSubstring("main-snippet"))
+		err = c.Get(ctx, client.ObjectKey{Name: utils.INGRESS_NGINX_CONTROLLER, Namespace: "tenant-ns"}, &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(<-reconciler.Recorder.(*record.FakeRecorder).Events).To(HavePrefix("Warning InvalidSpec"))
+	})
+})
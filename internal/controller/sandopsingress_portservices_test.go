@@ -0,0 +1,94 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("SandOpsIngress port services", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		reconciler *SandOpsIngressReconciler
+		ingress    *aasdevv1.SandOpsIngress
+		request    reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+
+		ingress = &aasdevv1.SandOpsIngress{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"},
+			Spec: aasdevv1.SandOpsIngressSpec{
+				TCPServices: []aasdevv1.PortService{{Port: 5432, ServiceName: "postgres", ServicePort: 5432}},
+			},
+		}
+		request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)}
+		c = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(ingress).
+			WithStatusSubresource(&aasdevv1.SandOpsIngress{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		reconciler = &SandOpsIngressReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+	})
+
+	It("maps the ports to Services of the tenant namespace", func() {
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		tcpServices := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: utils.TCPServicesConfigMapName("tenant"), Namespace: "tenant-ns"}, tcpServices)).To(Succeed())
+		Expect(tcpServices.Data).To(Equal(map[string]string{"5432": "tenant-ns/postgres:5432"}))
+	})
+
+	It("stalls on a Service in another namespace", func() {
+		Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+		ingress.Spec.TCPServices[0].ServiceNamespace = "kube-system"
+		Expect(c.Update(ctx, ingress)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).To(HaveOccurred())
+		Expect(c.Get(ctx, request.NamespacedName, ingress)).To(Succeed())
+		stalled := meta.FindStatusCondition(ingress.Status.Conditions, aasdevv1.IngressConditionStalled)
+		Expect(stalled).NotTo(BeNil())
+		Expect(stalled.Reason).To(Equal("InvalidPortService"))
+		err = c.Get(ctx, client.ObjectKey{Name: utils.TCPServicesConfigMapName("tenant"), Namespace: "tenant-ns"}, &corev1.ConfigMap{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,77 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+	"sandtech.io/sand-ops/internal/tracing"
+	"sandtech.io/sand-ops/internal/tracing/tracingtest"
+)
+
+var _ = Describe("SandOpsIngress tracing", func() {
+	It("traces every component as a child of the reconcile", func() {
+		ctx := context.Background()
+		collector, err := tracingtest.NewCollector()
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(collector.Stop)
+		shutdown, err := tracing.Setup(ctx, tracing.Options{Endpoint: collector.Endpoint(), Insecure: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(testScheme)).To(Succeed())
+		ingress := &aasdevv1.SandOpsIngress{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"}}
+		c := fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(ingress).
+			WithStatusSubresource(&aasdevv1.SandOpsIngress{}).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+		reconciler := &SandOpsIngressReconciler{Client: tracing.WrapClient(c), Scheme: testScheme, Recorder: record.NewFakeRecorder(100)}
+
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ingress)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shutdown(ctx)).To(Succeed())
+
+		spans := map[string][]byte{}
+		parents := map[string][]byte{}
+		for _, span := range collector.Spans() {
+			spans[span.GetName()] = span.GetSpanId()
+			parents[span.GetName()] = span.GetParentSpanId()
+		}
+		Expect(spans).To(HaveKey("Reconcile SandOpsIngress"))
+		for _, component := range reconciler.ingressComponents(ingress) {
+			Expect(spans).To(HaveKey(component.Name))
+			Expect(bytes.Equal(parents[component.Name], spans["Reconcile SandOpsIngress"])).To(BeTrue(), component.Name)
+		}
+		Expect(spans).To(HaveKey("k8s.patch"))
+	})
+})
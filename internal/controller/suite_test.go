@@ -41,6 +41,7 @@ import (
 
 var cfg *rest.Config
 var k8sClient client.Client
+var kubeClients KubeClients
 var testEnv *envtest.Environment
 
 func TestControllers(t *testing.T) {
@@ -81,6 +82,9 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
+	kubeClients, err = GetAllClients(cfg)
+	Expect(err).NotTo(HaveOccurred())
+
 })
 
 var _ = AfterSuite(func() {
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+// Phases an object is counted in, derived from its conditions.
+const (
+	// PhasePending objects have not been reconciled yet.
+	PhasePending = "Pending"
+	// PhaseReady objects are Ready.
+	PhaseReady = "Ready"
+	// PhaseNotReady objects are being rolled out or wait for a failed step to
+	// be retried.
+	PhaseNotReady = "NotReady"
+	// PhaseStalled objects failed in a way retrying can't fix.
+	PhaseStalled = "Stalled"
+	// PhaseInvalid frontends have a spec that can't be turned into objects.
+	PhaseInvalid = "Invalid"
+	// PhaseDeleting objects are being cleaned up.
+	PhaseDeleting = "Deleting"
+)
+
+var (
+	ingressesDesc = prometheus.NewDesc(
+		"sandops_sandopsingresses",
+		"SandOpsIngress objects by phase.",
+		[]string{"phase"}, nil,
+	)
+	frontendsDesc = prometheus.NewDesc(
+		"sandops_frontenddeploys",
+		"FrontendDeploy objects by phase.",
+		[]string{"phase"}, nil,
+	)
+	tenantFrontendsDesc = prometheus.NewDesc(
+		"sandops_tenant_frontends",
+		"FrontendDeploy objects of each tenant.",
+		[]string{"sandopsingress"}, nil,
+	)
+)
+
+// inventoryTimeout bounds the listing done for one scrape.
+const inventoryTimeout = 10 * time.Second
+
+// InventoryCollector counts SandOpsIngress and FrontendDeploy objects at scrape
+// time. It reads through the manager's cache, so a scrape doesn't reach the
+// API server.
+type InventoryCollector struct {
+	reader client.Reader
+}
+
+// NewInventoryCollector returns a collector listing objects through reader.
+func NewInventoryCollector(reader client.Reader) *InventoryCollector {
+	return &InventoryCollector{reader: reader}
+}
+
+// Describe implements prometheus.Collector.
+func (c *InventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ingressesDesc
+	ch <- frontendsDesc
+	ch <- tenantFrontendsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *InventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), inventoryTimeout)
+	defer cancel()
+
+	ingresses := &controllerapi.SandOpsIngressList{}
+	if err := c.reader.List(ctx, ingresses); err != nil {
+		ch <- prometheus.NewInvalidMetric(ingressesDesc, err)
+	} else {
+		phases := map[string]int{PhasePending: 0, PhaseReady: 0, PhaseNotReady: 0, PhaseStalled: 0, PhaseDeleting: 0}
+		for i := range ingresses.Items {
+			phases[IngressPhase(&ingresses.Items[i])]++
+		}
+		collectPhases(ch, ingressesDesc, phases)
+	}
+
+	frontends := &controllerapi.FrontendDeployList{}
+	if err := c.reader.List(ctx, frontends); err != nil {
+		ch <- prometheus.NewInvalidMetric(frontendsDesc, err)
+		ch <- prometheus.NewInvalidMetric(tenantFrontendsDesc, err)
+		return
+	}
+	phases := map[string]int{PhasePending: 0, PhaseReady: 0, PhaseNotReady: 0, PhaseStalled: 0, PhaseInvalid: 0, PhaseDeleting: 0}
+	tenants := map[string]int{}
+	for i := range frontends.Items {
+		frontend := &frontends.Items[i]
+		phases[FrontendPhase(frontend)]++
+		tenants[utils.IngressNamespacedName(frontend.Namespace).String()]++
+	}
+	collectPhases(ch, frontendsDesc, phases)
+	for tenant, count := range tenants {
+		ch <- prometheus.MustNewConstMetric(tenantFrontendsDesc, prometheus.GaugeValue, float64(count), tenant)
+	}
+}
+
+func collectPhases(ch chan<- prometheus.Metric, desc *prometheus.Desc, phases map[string]int) {
+	for phase, count := range phases {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(count), phase)
+	}
+}
+
+// IngressPhase derives the phase of a SandOpsIngress from its conditions.
+func IngressPhase(ingress *controllerapi.SandOpsIngress) string {
+	return phase(ingress, ingress.Status.Conditions, controllerapi.IngressConditionReady, controllerapi.IngressConditionStalled)
+}
+
+// FrontendPhase derives the phase of a FrontendDeploy from its conditions.
+func FrontendPhase(frontend *controllerapi.FrontendDeploy) string {
+	if frontend.DeletionTimestamp.IsZero() && meta.IsStatusConditionFalse(frontend.Status.Conditions, controllerapi.FrontendConditionValid) {
+		return PhaseInvalid
+	}
+	return phase(frontend, frontend.Status.Conditions, controllerapi.FrontendConditionReady, controllerapi.FrontendConditionStalled)
+}
+
+func phase(obj metav1.Object, conditions []metav1.Condition, ready, stalled string) string {
+	switch {
+	case !obj.GetDeletionTimestamp().IsZero():
+		return PhaseDeleting
+	case meta.IsStatusConditionTrue(conditions, stalled):
+		return PhaseStalled
+	case meta.IsStatusConditionTrue(conditions, ready):
+		return PhaseReady
+	case meta.FindStatusCondition(conditions, ready) == nil:
+		return PhasePending
+	default:
+		return PhaseNotReady
+	}
+}
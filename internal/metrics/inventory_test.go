@@ -0,0 +1,107 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+var _ = Describe("InventoryCollector", func() {
+	condition := func(conditionType string, status metav1.ConditionStatus) metav1.Condition {
+		return metav1.Condition{Type: conditionType, Status: status, Reason: "Test"}
+	}
+
+	// gather returns the value of every series of the collector, keyed by
+	// metric name and label value.
+	gather := func(objs ...client.Object) map[string]float64 {
+		testScheme := runtime.NewScheme()
+		Expect(controllerapi.AddToScheme(testScheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build()
+
+		registry := prometheus.NewRegistry()
+		Expect(registry.Register(NewInventoryCollector(c))).To(Succeed())
+		families, err := registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+
+		values := map[string]float64{}
+		for _, family := range families {
+			for _, metric := range family.GetMetric() {
+				values[family.GetName()+"/"+metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+			}
+		}
+		return values
+	}
+
+	It("counts objects by phase and frontends by tenant", func() {
+		ready := &controllerapi.SandOpsIngress{
+			ObjectMeta: metav1.ObjectMeta{Name: "ready", Namespace: "ready-ns"},
+			Status: controllerapi.SandOpsIngressStatus{Conditions: []metav1.Condition{
+				condition(controllerapi.IngressConditionReady, metav1.ConditionTrue),
+			}},
+		}
+		stalled := &controllerapi.SandOpsIngress{
+			ObjectMeta: metav1.ObjectMeta{Name: "stalled", Namespace: "stalled-ns"},
+			Status: controllerapi.SandOpsIngressStatus{Conditions: []metav1.Condition{
+				condition(controllerapi.IngressConditionReady, metav1.ConditionFalse),
+				condition(controllerapi.IngressConditionStalled, metav1.ConditionTrue),
+			}},
+		}
+		web := &controllerapi.FrontendDeploy{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ready-ns"},
+			Status: controllerapi.FrontendDeployStatus{Conditions: []metav1.Condition{
+				condition(controllerapi.FrontendConditionValid, metav1.ConditionTrue),
+				condition(controllerapi.FrontendConditionReady, metav1.ConditionFalse),
+			}},
+		}
+		invalid := &controllerapi.FrontendDeploy{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "ready-ns"},
+			Status: controllerapi.FrontendDeployStatus{Conditions: []metav1.Condition{
+				condition(controllerapi.FrontendConditionValid, metav1.ConditionFalse),
+			}},
+		}
+		pending := &controllerapi.FrontendDeploy{ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "stalled-ns"}}
+
+		values := gather(ready, stalled, web, invalid, pending)
+		Expect(values).To(HaveKeyWithValue("sandops_sandopsingresses/Ready", 1.0))
+		Expect(values).To(HaveKeyWithValue("sandops_sandopsingresses/Stalled", 1.0))
+		Expect(values).To(HaveKeyWithValue("sandops_sandopsingresses/Pending", 0.0))
+		Expect(values).To(HaveKeyWithValue("sandops_frontenddeploys/NotReady", 1.0))
+		Expect(values).To(HaveKeyWithValue("sandops_frontenddeploys/Invalid", 1.0))
+		Expect(values).To(HaveKeyWithValue("sandops_frontenddeploys/Pending", 1.0))
+		Expect(values).To(HaveKeyWithValue("sandops_tenant_frontends/ready-ns/ready", 2.0))
+		Expect(values).To(HaveKeyWithValue("sandops_tenant_frontends/stalled-ns/stalled", 1.0))
+	})
+
+	It("counts objects being deleted as Deleting whatever their conditions", func() {
+		now := metav1.Now()
+		deleting := &controllerapi.SandOpsIngress{
+			ObjectMeta: metav1.ObjectMeta{Name: "gone", Namespace: "gone-ns", DeletionTimestamp: &now, Finalizers: []string{"test"}},
+			Status: controllerapi.SandOpsIngressStatus{Conditions: []metav1.Condition{
+				condition(controllerapi.IngressConditionReady, metav1.ConditionTrue),
+			}},
+		}
+		Expect(IngressPhase(deleting)).To(Equal(PhaseDeleting))
+	})
+})
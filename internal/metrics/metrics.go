@@ -0,0 +1,73 @@
+// Package metrics holds the Prometheus metrics of the operator. They are
+// registered with the controller-runtime registry and served on the manager's
+// metrics endpoint.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Controller names used as the controller label.
+const (
+	ControllerSandOpsIngress = "sandopsingress"
+	ControllerFrontendDeploy = "frontenddeploy"
+)
+
+var (
+	// DriftCorrections counts generated objects reverted after being changed
+	// outside the operator.
+	DriftCorrections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandops_drift_corrections_total",
+		Help: "Generated objects reverted after being changed outside the operator.",
+	}, []string{"sandopsingress", "component"})
+
+	// DriftedComponents is the number of drifted objects a SandOpsIngress in
+	// report-only mode currently leaves alone.
+	DriftedComponents = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sandops_drifted_components",
+		Help: "Generated objects that drifted and are left alone because of driftPolicy ReportOnly.",
+	}, []string{"sandopsingress"})
+
+	// ReconcileStepDuration is how long applying one component or frontend
+	// object took. The component is the name used in the Ready condition.
+	ReconcileStepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sandops_reconcile_step_duration_seconds",
+		Help:    "Time taken to apply one component of a SandOpsIngress or object of a FrontendDeploy.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"controller", "component"})
+
+	// ReconcileStepFailures counts failed attempts to apply a component.
+	ReconcileStepFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandops_reconcile_step_failures_total",
+		Help: "Failed attempts to apply one component of a SandOpsIngress or object of a FrontendDeploy.",
+	}, []string{"controller", "component"})
+
+	// FrontendTimeToReady is the time from the creation of a FrontendDeploy
+	// until it first became Ready.
+	FrontendTimeToReady = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sandops_frontend_time_to_ready_seconds",
+		Help:    "Time from the creation of a FrontendDeploy until it first became Ready.",
+		Buckets: []float64{5, 10, 20, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		DriftCorrections,
+		DriftedComponents,
+		ReconcileStepDuration,
+		ReconcileStepFailures,
+		FrontendTimeToReady,
+	)
+}
+
+// ObserveStep records the duration of a reconcile step and whether it failed.
+func ObserveStep(controller, component string, duration time.Duration, err error) {
+	ReconcileStepDuration.WithLabelValues(controller, component).Observe(duration.Seconds())
+	if err != nil {
+		ReconcileStepFailures.WithLabelValues(controller, component).Inc()
+	}
+}
@@ -0,0 +1,170 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AppliedHashAnnotation holds the hash of the desired state an object was last
+// applied from. A live object carrying the current hash that an apply would
+// still change has been edited by someone else: it drifted.
+const AppliedHashAnnotation = "sandtech.io/applied-hash"
+
+// ApplyOptions change how Apply treats drift.
+type ApplyOptions struct {
+	// ReportOnly leaves drifted objects alone and reports them as Drifted
+	// instead of applying them again.
+	ReportOnly bool
+}
+
+// applyComponent applies one component, telling drift apart from changes to
+// the desired state.
+func applyComponent(ctx context.Context, c client.Client, component Component, opts ApplyOptions) Result {
+	result := Result{Component: component.Name, Action: ActionFailed}
+
+	obj := component.Object()
+	if err := component.Desired(ctx, obj); err != nil {
+		result.Err = err
+		return result
+	}
+	hash, err := desiredHash(obj)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[AppliedHashAnnotation] = hash
+	obj.SetAnnotations(annotations)
+
+	current := component.Object()
+	err = c.Get(ctx, client.ObjectKeyFromObject(obj), current)
+	if err != nil && !errors.IsNotFound(err) {
+		result.Err = err
+		return result
+	}
+	result.Object = ObjectName(c, obj)
+
+	if errors.IsNotFound(err) || current.GetAnnotations()[AppliedHashAnnotation] != hash {
+		// created, or the desired state changed since the last apply
+		result.Action, result.Err = ServerSideApply(ctx, c, obj)
+		return result
+	}
+
+	if opts.ReportOnly {
+		if result.Err = serverSideApply(ctx, c, obj, client.DryRunAll); result.Err != nil {
+			return result
+		}
+		result.Action = ActionUnchanged
+		if result.Drift, result.Err = driftedFields(current, obj); result.Err == nil && len(result.Drift) > 0 {
+			result.Action = ActionDrifted
+		}
+		return result
+	}
+
+	if result.Err = serverSideApply(ctx, c, obj); result.Err != nil {
+		return result
+	}
+	result.Action = ActionUnchanged
+	if obj.GetResourceVersion() == current.GetResourceVersion() {
+		return result
+	}
+	result.Action = ActionUpdated
+	if result.Drift, result.Err = driftedFields(current, obj); result.Err == nil && len(result.Drift) > 0 {
+		result.Action = ActionRepaired
+	}
+	return result
+}
+
+// desiredHash fingerprints the desired state of obj.
+func desiredHash(obj client.Object) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])[:16], nil
+}
+
+// ObjectName names obj by kind, namespace and name, like "Service/ns/name", or
+// by kind and name when it is cluster-scoped.
+func ObjectName(c client.Client, obj client.Object) string {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		if gvk, err := c.GroupVersionKindFor(obj); err == nil {
+			kind = gvk.Kind
+		}
+	}
+	if obj.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", kind, obj.GetName())
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, obj.GetNamespace(), obj.GetName())
+}
+
+// driftedFields lists the paths where before and after differ, leaving out
+// status and the metadata the API server maintains.
+func driftedFields(before, after client.Object) ([]string, error) {
+	beforeFields, err := comparableFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := comparableFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	diffFields("", beforeFields, afterFields, &fields)
+	sort.Strings(fields)
+	return fields, nil
+}
+
+func comparableFields(obj client.Object) (map[string]interface{}, error) {
+	fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "status")
+	delete(fields, "apiVersion")
+	delete(fields, "kind")
+	if metadata, ok := fields["metadata"].(map[string]interface{}); ok {
+		for _, key := range []string{"managedFields", "resourceVersion", "generation", "creationTimestamp", "uid"} {
+			delete(metadata, key)
+		}
+	}
+	return fields, nil
+}
+
+func diffFields(path string, before, after interface{}, fields *[]string) {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if !beforeIsMap || !afterIsMap {
+		if !reflect.DeepEqual(before, after) {
+			*fields = append(*fields, path)
+		}
+		return
+	}
+
+	keys := map[string]bool{}
+	for key := range beforeMap {
+		keys[key] = true
+	}
+	for key := range afterMap {
+		keys[key] = true
+	}
+	for key := range keys {
+		diffFields(strings.TrimPrefix(path+"."+key, "."), beforeMap[key], afterMap[key], fields)
+	}
+}
@@ -0,0 +1,361 @@
+// Package pipeline applies a graph of Kubernetes objects in dependency order.
+//
+// Every object is declared once as a Component. The Graph server-side applies
+// the components in dependency order, skips the dependents of a component
+// that failed, and deletes them again in reverse order.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"sandtech.io/sand-ops/internal/tracing"
+)
+
+// FieldManager owns the fields the operator applies. Fields set by anyone
+// else, such as extra labels and annotations, are left alone.
+const FieldManager = "sand-ops"
+
+// Component is one object managed by a Graph.
+type Component struct {
+	// Name identifies the component in DependsOn, results and conditions.
+	Name string
+
+	// DependsOn lists the components applied before this one and deleted after it.
+	DependsOn []string
+
+	// Object returns an empty object carrying only the name and, for
+	// namespaced kinds, the namespace.
+	Object func() client.Object
+
+	// Desired fills in the fields the operator owns on obj, the empty object
+	// returned by Object. Fields left unset are not touched on the live object.
+	Desired func(ctx context.Context, obj client.Object) error
+}
+
+// Action is what happened to a component.
+type Action string
+
+const (
+	ActionCreated   Action = "Created"
+	ActionUpdated   Action = "Updated"
+	ActionUnchanged Action = "Unchanged"
+	ActionDeleted   Action = "Deleted"
+	ActionAbsent    Action = "Absent"
+	ActionFailed    Action = "Failed"
+	ActionBlocked   Action = "Blocked"
+
+	// ActionRepaired is an update that reverted changes made outside the operator.
+	ActionRepaired Action = "Repaired"
+	// ActionDrifted is an object changed outside the operator and left alone.
+	ActionDrifted Action = "Drifted"
+)
+
+// Result is the outcome for one component.
+type Result struct {
+	Component string
+	Action    Action
+	Err       error
+
+	// Object is the kind, namespace and name of the applied object.
+	Object string
+	// Drift lists the fields that were changed outside the operator, for
+	// Repaired and Drifted components.
+	Drift []string
+	// Duration is how long applying the component took. Blocked components
+	// take none.
+	Duration time.Duration
+}
+
+// Graph is a validated, ordered set of components.
+type Graph struct {
+	order []Component
+}
+
+// New orders the components so every component comes after its
+// dependencies. Components without a dependency between them keep the order
+// they were declared in.
+func New(components ...Component) (*Graph, error) {
+	byName := map[string]Component{}
+	for _, component := range components {
+		if component.Name == "" {
+			return nil, fmt.Errorf("component without a name")
+		}
+		if _, ok := byName[component.Name]; ok {
+			return nil, fmt.Errorf("duplicate component %q", component.Name)
+		}
+		byName[component.Name] = component
+	}
+	for _, component := range components {
+		for _, dependency := range component.DependsOn {
+			if _, ok := byName[dependency]; !ok {
+				return nil, fmt.Errorf("component %q depends on unknown component %q", component.Name, dependency)
+			}
+		}
+	}
+
+	order := make([]Component, 0, len(components))
+	placed := map[string]bool{}
+	for len(order) < len(components) {
+		progressed := false
+		for _, component := range components {
+			if placed[component.Name] || !dependenciesPlaced(component, placed) {
+				continue
+			}
+			order = append(order, component)
+			placed[component.Name] = true
+			progressed = true
+			// start over so an earlier declared component goes first once it is ready
+			break
+		}
+		if !progressed {
+			var cycle []string
+			for _, component := range components {
+				if !placed[component.Name] {
+					cycle = append(cycle, component.Name)
+				}
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("dependency cycle between components %s", strings.Join(cycle, ", "))
+		}
+	}
+
+	return &Graph{order: order}, nil
+}
+
+func dependenciesPlaced(component Component, placed map[string]bool) bool {
+	for _, dependency := range component.DependsOn {
+		if !placed[dependency] {
+			return false
+		}
+	}
+	return true
+}
+
+// Components returns the component names in apply order.
+func (g *Graph) Components() []string {
+	names := make([]string, 0, len(g.order))
+	for _, component := range g.order {
+		names = append(names, component.Name)
+	}
+	return names
+}
+
+// Apply server-side applies every component in dependency order. A component
+// whose dependency failed is not applied and reported as Blocked. The
+// returned error aggregates the failures.
+func (g *Graph) Apply(ctx context.Context, c client.Client, l logr.Logger, opts ApplyOptions) ([]Result, error) {
+	results := make([]Result, 0, len(g.order))
+	failed := map[string]bool{}
+	var errs []error
+
+	for _, component := range g.order {
+		if blocker := firstFailed(component, failed); blocker != "" {
+			failed[component.Name] = true
+			results = append(results, Result{
+				Component: component.Name,
+				Action:    ActionBlocked,
+				Err:       fmt.Errorf("waiting for %s", blocker),
+			})
+			continue
+		}
+
+		start := time.Now()
+		stepCtx, span := tracing.StartStep(ctx, component.Name)
+		result := applyComponent(stepCtx, c, component, opts)
+		result.Duration = time.Since(start)
+		span.SetAttributes(attribute.String("sandops.object", result.Object), attribute.String("sandops.action", string(result.Action)))
+		tracing.End(span, result.Err)
+		if result.Err != nil {
+			failed[component.Name] = true
+			result.Action = ActionFailed
+			result.Err = fmt.Errorf("%s: %w", component.Name, result.Err)
+			errs = append(errs, result.Err)
+			results = append(results, result)
+			continue
+		}
+
+		switch result.Action {
+		case ActionUnchanged:
+		case ActionRepaired, ActionDrifted:
+			l.Info(fmt.Sprintf("%s %s %s", strings.ToLower(string(result.Action)), component.Name, result.Object), "fields", result.Drift)
+		default:
+			l.Info(fmt.Sprintf("%s %s %s", strings.ToLower(string(result.Action)), component.Name, result.Object))
+		}
+		results = append(results, result)
+	}
+
+	return results, utilerrors.NewAggregate(errs)
+}
+
+// ServerSideApply applies obj as FieldManager, taking over conflicting fields
+// from other managers. Only the fields set on obj are owned, so changes other
+// actors make to the rest of the object survive. It reports whether obj was
+// created, updated or already up to date.
+func ServerSideApply(ctx context.Context, c client.Client, obj client.Object) (Action, error) {
+	current := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), current)
+	if err != nil && !errors.IsNotFound(err) {
+		return ActionFailed, err
+	}
+	exists := err == nil
+
+	if err := serverSideApply(ctx, c, obj); err != nil {
+		return ActionFailed, err
+	}
+
+	switch {
+	case !exists:
+		return ActionCreated, nil
+	case obj.GetResourceVersion() != current.GetResourceVersion():
+		return ActionUpdated, nil
+	default:
+		return ActionUnchanged, nil
+	}
+}
+
+// ServerSideApplyDryRun applies obj like ServerSideApply without persisting
+// it, so the API server validates and admits it. obj is left as the API
+// server would have stored it.
+func ServerSideApplyDryRun(ctx context.Context, c client.Client, obj client.Object) error {
+	return serverSideApply(ctx, c, obj, client.DryRunAll)
+}
+
+func serverSideApply(ctx context.Context, c client.Client, obj client.Object, opts ...client.PatchOption) error {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+
+	opts = append(opts, client.FieldOwner(FieldManager), client.ForceOwnership)
+	return c.Patch(ctx, obj, client.Apply, opts...)
+}
+
+func firstFailed(component Component, failed map[string]bool) string {
+	for _, dependency := range component.DependsOn {
+		if failed[dependency] {
+			return dependency
+		}
+	}
+	return ""
+}
+
+// Delete removes the components in reverse dependency order. It carries on
+// past failures and aggregates them.
+func (g *Graph) Delete(ctx context.Context, c client.Client, l logr.Logger) ([]Result, error) {
+	results := make([]Result, 0, len(g.order))
+	var errs []error
+
+	for i := len(g.order) - 1; i >= 0; i-- {
+		component := g.order[i]
+		obj := component.Object()
+		name := ObjectName(c, obj)
+		err := c.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		switch {
+		case errors.IsNotFound(err):
+			results = append(results, Result{Component: component.Name, Action: ActionAbsent, Object: name})
+		case err != nil:
+			err = fmt.Errorf("%s: %w", component.Name, err)
+			errs = append(errs, err)
+			results = append(results, Result{Component: component.Name, Action: ActionFailed, Err: err, Object: name})
+		default:
+			l.Info(fmt.Sprintf("deleted %s %s", component.Name, client.ObjectKeyFromObject(obj)))
+			results = append(results, Result{Component: component.Name, Action: ActionDeleted, Object: name})
+		}
+	}
+
+	return results, utilerrors.NewAggregate(errs)
+}
+
+// Sweep deletes every object labelled with selector whose kind is one of the
+// components' kinds, in any namespace. It catches what Delete can't know
+// about, such as objects of components that were renamed or dropped. The
+// results name the objects rather than components.
+func (g *Graph) Sweep(ctx context.Context, c client.Client, l logr.Logger, selector map[string]string) ([]Result, error) {
+	var results []Result
+	var errs []error
+
+	seen := map[schema.GroupVersionKind]bool{}
+	for i := len(g.order) - 1; i >= 0; i-- {
+		component := g.order[i]
+		gvk, err := apiutil.GVKForObject(component.Object(), c.Scheme())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", component.Name, err))
+			continue
+		}
+		if seen[gvk] {
+			continue
+		}
+		seen[gvk] = true
+
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := c.List(ctx, list, client.MatchingLabels(selector)); err != nil {
+			errs = append(errs, fmt.Errorf("listing %s: %w", gvk.Kind, err))
+			continue
+		}
+		for j := range list.Items {
+			obj := &list.Items[j]
+			obj.SetGroupVersionKind(gvk)
+			name := ObjectName(c, obj)
+			err := c.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+			switch {
+			case errors.IsNotFound(err):
+			case err != nil:
+				err = fmt.Errorf("%s: %w", name, err)
+				errs = append(errs, err)
+				results = append(results, Result{Object: name, Action: ActionFailed, Err: err})
+			default:
+				l.Info(fmt.Sprintf("swept %s", name))
+				results = append(results, Result{Object: name, Action: ActionDeleted})
+			}
+		}
+	}
+
+	return results, utilerrors.NewAggregate(errs)
+}
+
+// ReadyCondition summarises the results of Apply into a Ready condition.
+func ReadyCondition(conditionType string, results []Result, generation int64) metav1.Condition {
+	var problems []string
+	for _, result := range results {
+		switch result.Action {
+		case ActionFailed:
+			problems = append(problems, result.Err.Error())
+		case ActionBlocked:
+			problems = append(problems, fmt.Sprintf("%s: %v", result.Component, result.Err))
+		}
+	}
+
+	if len(problems) == 0 {
+		return metav1.Condition{
+			Type:               conditionType,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Reconciled",
+			Message:            fmt.Sprintf("%d components applied", len(results)),
+			ObservedGeneration: generation,
+		}
+	}
+	return metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionFalse,
+		Reason:             "ComponentFailed",
+		Message:            strings.Join(problems, "; "),
+		ObservedGeneration: generation,
+	}
+}
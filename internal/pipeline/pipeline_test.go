@@ -0,0 +1,254 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"sandtech.io/sand-ops/internal/pipeline/pipelinetest"
+)
+
+func configMapComponent(name string, data map[string]string, dependsOn ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Object: func() client.Object {
+			return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		},
+		Desired: func(_ context.Context, obj client.Object) error {
+			obj.(*corev1.ConfigMap).Data = data
+			return nil
+		},
+	}
+}
+
+var _ = Describe("Graph", func() {
+	var (
+		ctx context.Context
+		c   client.Client
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		c = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithInterceptorFuncs(pipelinetest.Interceptor()).
+			Build()
+	})
+
+	It("orders components after their dependencies", func() {
+		graph, err := New(
+			configMapComponent("deployment", nil, "config", "account"),
+			configMapComponent("config", nil, "namespace"),
+			configMapComponent("namespace", nil),
+			configMapComponent("account", nil, "namespace"),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(graph.Components()).To(Equal([]string{"namespace", "config", "account", "deployment"}))
+	})
+
+	It("rejects unknown dependencies, duplicates and cycles", func() {
+		_, err := New(configMapComponent("a", nil, "missing"))
+		Expect(err).To(MatchError(ContainSubstring("unknown component")))
+
+		_, err = New(configMapComponent("a", nil), configMapComponent("a", nil))
+		Expect(err).To(MatchError(ContainSubstring("duplicate")))
+
+		_, err = New(configMapComponent("a", nil, "b"), configMapComponent("b", nil, "a"))
+		Expect(err).To(MatchError(ContainSubstring("cycle between components a, b")))
+	})
+
+	It("creates, updates and leaves unchanged objects alone", func() {
+		graph, err := New(configMapComponent("config", map[string]string{"a": "1"}))
+		Expect(err).NotTo(HaveOccurred())
+
+		results, err := graph.Apply(ctx, c, logr.Discard(), ApplyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results[0].Action).To(Equal(ActionCreated))
+
+		results, err = graph.Apply(ctx, c, logr.Discard(), ApplyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results[0].Action).To(Equal(ActionUnchanged))
+
+		graph, err = New(configMapComponent("config", map[string]string{"a": "2"}))
+		Expect(err).NotTo(HaveOccurred())
+		results, err = graph.Apply(ctx, c, logr.Discard(), ApplyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results[0].Action).To(Equal(ActionUpdated))
+
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "config", Namespace: "default"}, configMap)).To(Succeed())
+		Expect(configMap.Data).To(HaveKeyWithValue("a", "2"))
+	})
+
+	It("leaves labels and annotations added by others alone", func() {
+		graph, err := New(configMapComponent("config", map[string]string{"a": "1"}))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = graph.Apply(ctx, c, logr.Discard(), ApplyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		configMap := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: "config", Namespace: "default"}, configMap)).To(Succeed())
+		configMap.Labels = map[string]string{"team": "web"}
+		configMap.Annotations["example.com/owner"] = "someone"
+		Expect(c.Update(ctx, configMap)).To(Succeed())
+
+		results, err := graph.Apply(ctx, c, logr.Discard(), ApplyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results[0].Action).To(Equal(ActionUnchanged))
+
+		Expect(c.Get(ctx, client.ObjectKey{Name: "config", Namespace: "default"}, configMap)).To(Succeed())
+		Expect(configMap.Labels).To(HaveKeyWithValue("team", "web"))
+		Expect(configMap.Annotations).To(HaveKeyWithValue("example.com/owner", "someone"))
+		Expect(configMap.Data).To(HaveKeyWithValue("a", "1"))
+	})
+
+	Context("when an object is changed by hand", func() {
+		var graph *Graph
+
+		BeforeEach(func() {
+			var err error
+			graph, err = New(configMapComponent("config", map[string]string{"a": "1"}))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = graph.Apply(ctx, c, logr.Discard(), ApplyOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			configMap := &corev1.ConfigMap{}
+			Expect(c.Get(ctx, client.ObjectKey{Name: "config", Namespace: "default"}, configMap)).To(Succeed())
+			configMap.Data["a"] = "edited"
+			Expect(c.Update(ctx, configMap)).To(Succeed())
+		})
+
+		It("repairs it and reports the drifted fields", func() {
+			results, err := graph.Apply(ctx, c, logr.Discard(), ApplyOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Action).To(Equal(ActionRepaired))
+			Expect(results[0].Drift).To(Equal([]string{"data.a"}))
+			Expect(results[0].Object).To(Equal("ConfigMap/default/config"))
+
+			configMap := &corev1.ConfigMap{}
+			Expect(c.Get(ctx, client.ObjectKey{Name: "config", Namespace: "default"}, configMap)).To(Succeed())
+			Expect(configMap.Data).To(HaveKeyWithValue("a", "1"))
+		})
+
+		It("only reports it in report-only mode", func() {
+			results, err := graph.Apply(ctx, c, logr.Discard(), ApplyOptions{ReportOnly: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Action).To(Equal(ActionDrifted))
+			Expect(results[0].Drift).To(Equal([]string{"data.a"}))
+
+			configMap := &corev1.ConfigMap{}
+			Expect(c.Get(ctx, client.ObjectKey{Name: "config", Namespace: "default"}, configMap)).To(Succeed())
+			Expect(configMap.Data).To(HaveKeyWithValue("a", "edited"))
+		})
+
+		It("treats a new desired state as an update rather than drift", func() {
+			graph, err := New(configMapComponent("config", map[string]string{"a": "2"}))
+			Expect(err).NotTo(HaveOccurred())
+			results, err := graph.Apply(ctx, c, logr.Discard(), ApplyOptions{ReportOnly: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Action).To(Equal(ActionUpdated))
+			Expect(results[0].Drift).To(BeEmpty())
+		})
+	})
+
+	It("blocks dependents of a failed component and keeps going with the rest", func() {
+		broken := configMapComponent("broken", nil)
+		broken.Desired = func(context.Context, client.Object) error {
+			return fmt.Errorf("boom")
+		}
+		graph, err := New(
+			broken,
+			configMapComponent("dependent", nil, "broken"),
+			configMapComponent("independent", nil),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		results, err := graph.Apply(ctx, c, logr.Discard(), ApplyOptions{})
+		Expect(err).To(MatchError(ContainSubstring("broken: boom")))
+		Expect(results).To(HaveLen(3))
+		Expect(results[0].Action).To(Equal(ActionFailed))
+		Expect(results[1].Action).To(Equal(ActionBlocked))
+		Expect(results[2].Action).To(Equal(ActionCreated))
+
+		condition := ReadyCondition("Ready", results, 3)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Message).To(Equal("broken: boom; dependent: waiting for broken"))
+		Expect(condition.ObservedGeneration).To(BeEquivalentTo(3))
+	})
+
+	It("deletes components in reverse order and ignores missing ones", func() {
+		graph, err := New(
+			configMapComponent("first", nil),
+			configMapComponent("second", nil, "first"),
+			configMapComponent("never-created", nil),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = graph.Apply(ctx, c, logr.Discard(), ApplyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "never-created", Namespace: "default"}})).To(Succeed())
+
+		results, err := graph.Delete(ctx, c, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(Equal([]Result{
+			{Component: "never-created", Action: ActionAbsent, Object: "ConfigMap/default/never-created"},
+			{Component: "second", Action: ActionDeleted, Object: "ConfigMap/default/second"},
+			{Component: "first", Action: ActionDeleted, Object: "ConfigMap/default/first"},
+		}))
+
+		err = c.Get(ctx, client.ObjectKey{Name: "first", Namespace: "default"}, &corev1.ConfigMap{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("sweeps labelled objects of the component kinds in every namespace", func() {
+		labelled := func(name, namespace string, labels map[string]string) *corev1.ConfigMap {
+			return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}
+		}
+		tenant := map[string]string{"tenant": "a"}
+		Expect(c.Create(ctx, labelled("renamed", "default", tenant))).To(Succeed())
+		Expect(c.Create(ctx, labelled("elsewhere", "other", tenant))).To(Succeed())
+		Expect(c.Create(ctx, labelled("other-tenant", "default", map[string]string{"tenant": "b"}))).To(Succeed())
+
+		graph, err := New(configMapComponent("config", nil))
+		Expect(err).NotTo(HaveOccurred())
+		results, err := graph.Sweep(ctx, c, logr.Discard(), tenant)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(ConsistOf(
+			Result{Object: "ConfigMap/default/renamed", Action: ActionDeleted},
+			Result{Object: "ConfigMap/other/elsewhere", Action: ActionDeleted},
+		))
+
+		remaining := &corev1.ConfigMapList{}
+		Expect(c.List(ctx, remaining)).To(Succeed())
+		Expect(remaining.Items).To(HaveLen(1))
+		Expect(remaining.Items[0].Name).To(Equal("other-tenant"))
+	})
+})
@@ -0,0 +1,83 @@
+// Package pipelinetest helps testing code built on the pipeline package with
+// the controller-runtime fake client.
+package pipelinetest
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// Interceptor makes a fake client accept server-side apply patches, for
+// fake.ClientBuilder.WithInterceptorFuncs.
+func Interceptor() interceptor.Funcs {
+	return interceptor.Funcs{Patch: ApplyPatch}
+}
+
+// ApplyPatch stands in for server-side apply, which the fake client doesn't
+// support. The applied object is merged into the stored one, so fields it
+// leaves out are kept, and an apply that changes nothing isn't written.
+func ApplyPatch(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+
+	key := client.ObjectKeyFromObject(obj)
+	current := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, key, current)
+	if errors.IsNotFound(err) {
+		if dryRun(opts) {
+			return nil
+		}
+		return c.Create(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+
+	original, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	applied, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	merged, err := strategicpatch.StrategicMergePatch(original, applied, obj)
+	if err != nil {
+		return err
+	}
+	desired := current.DeepCopyObject().(client.Object)
+	if err := json.Unmarshal(merged, desired); err != nil {
+		return err
+	}
+	if dryRun(opts) {
+		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(desired).Elem())
+		return nil
+	}
+	if !equality.Semantic.DeepEqual(desired, current) {
+		if err := c.Update(ctx, desired); err != nil {
+			return err
+		}
+	}
+	return c.Get(ctx, key, obj)
+}
+
+func dryRun(opts []client.PatchOption) bool {
+	patchOptions := &client.PatchOptions{}
+	patchOptions.ApplyOptions(opts)
+	for _, option := range patchOptions.DryRun {
+		if option == metav1.DryRunAll {
+			return true
+		}
+	}
+	return false
+}
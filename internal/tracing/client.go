@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WrapClient returns a client tracing every call with a span named after the
+// verb and carrying the kind, namespace and name of the object.
+func WrapClient(c client.Client) client.Client {
+	return &tracingClient{Client: c}
+}
+
+type tracingClient struct {
+	client.Client
+}
+
+// start starts the span of a call on obj. Lists carry the kind of their items
+// and no name.
+func start(ctx context.Context, c client.Client, verb string, obj runtime.Object, subResource string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{attribute.String("k8s.verb", verb)}
+	if gvk, err := c.GroupVersionKindFor(obj); err == nil {
+		kind := gvk.Kind
+		if meta.IsListType(obj) {
+			kind = strings.TrimSuffix(kind, "List")
+		}
+		attributes = append(attributes, attribute.String("k8s.kind", kind))
+	}
+	if object, ok := obj.(client.Object); ok {
+		if object.GetNamespace() != "" {
+			attributes = append(attributes, attribute.String("k8s.namespace", object.GetNamespace()))
+		}
+		if object.GetName() != "" {
+			attributes = append(attributes, attribute.String("k8s.name", object.GetName()))
+		}
+	}
+	name := "k8s." + verb
+	if subResource != "" {
+		attributes = append(attributes, attribute.String("k8s.subresource", subResource))
+		name += " " + subResource
+	}
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+func (c *tracingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	obj.SetNamespace(key.Namespace)
+	obj.SetName(key.Name)
+	ctx, span := start(ctx, c.Client, "get", obj, "")
+	err := c.Client.Get(ctx, key, obj, opts...)
+	End(span, err)
+	return err
+}
+
+func (c *tracingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	ctx, span := start(ctx, c.Client, "list", list, "")
+	err := c.Client.List(ctx, list, opts...)
+	End(span, err)
+	return err
+}
+
+func (c *tracingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	ctx, span := start(ctx, c.Client, "create", obj, "")
+	err := c.Client.Create(ctx, obj, opts...)
+	End(span, err)
+	return err
+}
+
+func (c *tracingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	ctx, span := start(ctx, c.Client, "delete", obj, "")
+	err := c.Client.Delete(ctx, obj, opts...)
+	End(span, err)
+	return err
+}
+
+func (c *tracingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	ctx, span := start(ctx, c.Client, "update", obj, "")
+	err := c.Client.Update(ctx, obj, opts...)
+	End(span, err)
+	return err
+}
+
+func (c *tracingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	ctx, span := start(ctx, c.Client, "patch", obj, "")
+	span.SetAttributes(attribute.String("k8s.patch_type", string(patch.Type())))
+	err := c.Client.Patch(ctx, obj, patch, opts...)
+	End(span, err)
+	return err
+}
+
+func (c *tracingClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	ctx, span := start(ctx, c.Client, "deletecollection", obj, "")
+	err := c.Client.DeleteAllOf(ctx, obj, opts...)
+	End(span, err)
+	return err
+}
+
+func (c *tracingClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *tracingClient) SubResource(subResource string) client.SubResourceClient {
+	return &tracingSubResourceClient{client: c.Client, subResource: subResource, SubResourceClient: c.Client.SubResource(subResource)}
+}
+
+type tracingSubResourceClient struct {
+	client.SubResourceClient
+	client      client.Client
+	subResource string
+}
+
+func (c *tracingSubResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	ctx, span := start(ctx, c.client, "get", obj, c.subResource)
+	err := c.SubResourceClient.Get(ctx, obj, subResource, opts...)
+	End(span, err)
+	return err
+}
+
+func (c *tracingSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	ctx, span := start(ctx, c.client, "create", obj, c.subResource)
+	err := c.SubResourceClient.Create(ctx, obj, subResource, opts...)
+	End(span, err)
+	return err
+}
+
+func (c *tracingSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	ctx, span := start(ctx, c.client, "update", obj, c.subResource)
+	err := c.SubResourceClient.Update(ctx, obj, opts...)
+	End(span, err)
+	return err
+}
+
+func (c *tracingSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	ctx, span := start(ctx, c.client, "patch", obj, c.subResource)
+	err := c.SubResourceClient.Patch(ctx, obj, patch, opts...)
+	End(span, err)
+	return err
+}
@@ -0,0 +1,107 @@
+// Package tracing exports OpenTelemetry traces of the reconcilers over OTLP.
+//
+// Every Reconcile is a root span, every step a child span and every call to
+// the Kubernetes API a span carrying the kind and name of the object. Tracing
+// is off unless an OTLP endpoint is configured, the spans are no-ops then.
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ServiceName identifies the operator in the exported traces.
+const ServiceName = "sand-ops"
+
+const instrumentationName = "sandtech.io/sand-ops"
+
+// Options configure the OTLP exporter. Settings left empty fall back to the
+// standard OTEL_EXPORTER_OTLP_* environment variables.
+type Options struct {
+	// Endpoint is the host:port of the OTLP gRPC receiver.
+	Endpoint string
+	// Insecure disables TLS towards the receiver.
+	Insecure bool
+}
+
+// Enabled reports whether an endpoint is configured by opts or the environment.
+func (o Options) Enabled() bool {
+	return o.Endpoint != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the global tracer provider exporting to the configured OTLP
+// endpoint. The returned function flushes the pending spans and stops the
+// exporter. Without an endpoint Setup does nothing.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if !opts.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporterOpts []otlptracegrpc.Option
+	if opts.Endpoint != "" {
+		exporterOpts = append(exporterOpts, otlptracegrpc.WithEndpoint(opts.Endpoint))
+	}
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// StartReconcile starts the root span of a reconcile of the object kind
+// named by key.
+func StartReconcile(ctx context.Context, kind string, key types.NamespacedName) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "Reconcile "+kind,
+		trace.WithNewRoot(),
+		trace.WithAttributes(
+			attribute.String("k8s.kind", kind),
+			attribute.String("k8s.namespace", key.Namespace),
+			attribute.String("k8s.name", key.Name),
+		),
+	)
+}
+
+// StartStep starts the span of one step of a reconcile.
+func StartStep(ctx context.Context, step string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, step, trace.WithAttributes(attribute.String("sandops.step", step)))
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"sandtech.io/sand-ops/internal/tracing/tracingtest"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		ctx       context.Context
+		collector *tracingtest.Collector
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		collector, err = tracingtest.NewCollector()
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(collector.Stop)
+	})
+
+	spanNamed := func(spans []*tracepb.Span, name string) *tracepb.Span {
+		for _, span := range spans {
+			if span.GetName() == name {
+				return span
+			}
+		}
+		return nil
+	}
+
+	It("exports a reconcile with its steps and API calls to the collector", func() {
+		shutdown, err := Setup(ctx, Options{Endpoint: collector.Endpoint(), Insecure: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		c := WrapClient(fake.NewClientBuilder().WithObjects(
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "tenant-ns"}},
+		).Build())
+
+		reconcileCtx, reconcileSpan := StartReconcile(ctx, "SandOpsIngress", types.NamespacedName{Name: "tenant", Namespace: "tenant-ns"})
+		stepCtx, stepSpan := StartStep(reconcileCtx, "configmap")
+		Expect(c.Get(stepCtx, client.ObjectKey{Name: "config", Namespace: "tenant-ns"}, &corev1.ConfigMap{})).To(Succeed())
+		Expect(c.Get(stepCtx, client.ObjectKey{Name: "missing", Namespace: "tenant-ns"}, &corev1.ConfigMap{})).NotTo(Succeed())
+		End(stepSpan, nil)
+		End(reconcileSpan, nil)
+		Expect(shutdown(ctx)).To(Succeed())
+
+		spans := collector.Spans()
+		root := spanNamed(spans, "Reconcile SandOpsIngress")
+		Expect(root).NotTo(BeNil())
+		Expect(root.GetParentSpanId()).To(BeEmpty())
+		Expect(tracingtest.Attributes(root)).To(HaveKeyWithValue("k8s.name", "tenant"))
+
+		step := spanNamed(spans, "configmap")
+		Expect(step).NotTo(BeNil())
+		Expect(bytes.Equal(step.GetParentSpanId(), root.GetSpanId())).To(BeTrue())
+
+		var calls []*tracepb.Span
+		for _, span := range spans {
+			if span.GetName() == "k8s.get" {
+				calls = append(calls, span)
+			}
+		}
+		Expect(calls).To(HaveLen(2))
+		for _, call := range calls {
+			Expect(bytes.Equal(call.GetParentSpanId(), step.GetSpanId())).To(BeTrue())
+			Expect(tracingtest.Attributes(call)).To(HaveKeyWithValue("k8s.kind", "ConfigMap"))
+			Expect(tracingtest.Attributes(call)).To(HaveKeyWithValue("k8s.namespace", "tenant-ns"))
+		}
+		Expect(tracingtest.Attributes(calls[0])).To(HaveKeyWithValue("k8s.name", "config"))
+		Expect(calls[1].GetStatus().GetCode()).To(Equal(tracepb.Status_STATUS_CODE_ERROR))
+	})
+
+	It("stays off without an endpoint", func() {
+		GinkgoT().Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		GinkgoT().Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+		Expect(Options{}.Enabled()).To(BeFalse())
+
+		shutdown, err := Setup(ctx, Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shutdown(ctx)).To(Succeed())
+	})
+})
@@ -0,0 +1,77 @@
+// Package tracingtest provides a stand-in for an OpenTelemetry collector, so
+// tests can check the spans the operator exports over OTLP.
+package tracingtest
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+)
+
+// Collector is an OTLP gRPC trace receiver on a local port that keeps every
+// span it receives.
+type Collector struct {
+	collectortrace.UnimplementedTraceServiceServer
+
+	listener net.Listener
+	server   *grpc.Server
+
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+// NewCollector starts a collector on a free local port.
+func NewCollector() (*Collector, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	c := &Collector{listener: listener, server: grpc.NewServer()}
+	collectortrace.RegisterTraceServiceServer(c.server, c)
+	go func() {
+		_ = c.server.Serve(listener)
+	}()
+	return c, nil
+}
+
+// Endpoint is the host:port to export to, without TLS.
+func (c *Collector) Endpoint() string {
+	return c.listener.Addr().String()
+}
+
+// Stop shuts the collector down.
+func (c *Collector) Stop() {
+	c.server.Stop()
+}
+
+// Export implements the OTLP trace service.
+func (c *Collector) Export(_ context.Context, request *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, resourceSpans := range request.GetResourceSpans() {
+		for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+			c.spans = append(c.spans, scopeSpans.GetSpans()...)
+		}
+	}
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+// Spans returns the spans received so far.
+func (c *Collector) Spans() []*tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*tracepb.Span(nil), c.spans...)
+}
+
+// Attributes returns the string attributes of span.
+func Attributes(span *tracepb.Span) map[string]string {
+	values := map[string]string{}
+	for _, attribute := range span.GetAttributes() {
+		values[attribute.GetKey()] = attribute.GetValue().GetStringValue()
+	}
+	return values
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+// Ports of the error backend. One nginx serves both pages, the maintenance
+// Service points at its own port so it always answers 503.
+const (
+	ERROR_BACKEND_PORT       = 8080
+	MAINTENANCE_BACKEND_PORT = 8081
+)
+
+// Keys of the error pages ConfigMap, mounted as the nginx conf.d directory.
+const (
+	ErrorPagesConfigKey        = "default.conf"
+	ErrorPageKey               = "error.html"
+	MaintenancePageKey         = "maintenance.html"
+	DefaultErrorPageHTML       = "<!DOCTYPE html>\n<html>\n<head><title>Error</title></head>\n<body>\n<h1>Something went wrong</h1>\n<p>The page could not be served. Please try again later.</p>\n</body>\n</html>\n"
+	DefaultMaintenancePageHTML = "<!DOCTYPE html>\n<html>\n<head><title>Maintenance</title></head>\n<body>\n<h1>Down for maintenance</h1>\n<p>We will be back shortly.</p>\n</body>\n</html>\n"
+)
+
+// ErrorPageCodes lists the codes the error backend answers, sorted. 404 is
+// always there since ingress-nginx sends requests no Ingress matches to the
+// default backend.
+func ErrorPageCodes(ingressDeployment *controllerapi.SandOpsIngress) []int32 {
+	codes := []int32{404}
+	if pages := ingressDeployment.Spec.CustomErrorPages; pages != nil {
+		codes = append(codes, pages.Codes...)
+	}
+	slices.Sort(codes)
+	return slices.Compact(codes)
+}
+
+// CustomHTTPErrors renders the custom-http-errors ConfigMap value, empty when
+// the tenant keeps the responses of its frontends.
+func CustomHTTPErrors(ingressDeployment *controllerapi.SandOpsIngress) string {
+	pages := ingressDeployment.Spec.CustomErrorPages
+	if pages == nil {
+		return ""
+	}
+	codes := slices.Clone(pages.Codes)
+	slices.Sort(codes)
+	values := make([]string, 0, len(codes))
+	for _, code := range slices.Compact(codes) {
+		values = append(values, strconv.Itoa(int(code)))
+	}
+	return strings.Join(values, ",")
+}
+
+// ErrorPagesData renders the ConfigMap of the error backend: the nginx server
+// configuration and the two pages. ingress-nginx passes the status code of an
+// intercepted response in the X-Code header, the error server answers with it.
+func ErrorPagesData(ingressDeployment *controllerapi.SandOpsIngress) map[string]string {
+	errorPage, maintenancePage := DefaultErrorPageHTML, DefaultMaintenancePageHTML
+	if pages := ingressDeployment.Spec.CustomErrorPages; pages != nil && pages.Page != "" {
+		errorPage = pages.Page
+	}
+	if ingressDeployment.Spec.MaintenancePage != "" {
+		maintenancePage = ingressDeployment.Spec.MaintenancePage
+	}
+
+	codes := ErrorPageCodes(ingressDeployment)
+	var config strings.Builder
+	fmt.Fprintf(&config, "server {\n    listen %d;\n    root /etc/nginx/conf.d;\n", ERROR_BACKEND_PORT)
+	for _, code := range codes {
+		fmt.Fprintf(&config, "    error_page %d /%s;\n", code, ErrorPageKey)
+	}
+	fmt.Fprintf(&config, "    location = /%s {\n        internal;\n    }\n", ErrorPageKey)
+	config.WriteString("    location = /healthz {\n        access_log off;\n        return 200;\n    }\n")
+	config.WriteString("    location / {\n")
+	for _, code := range codes {
+		fmt.Fprintf(&config, "        if ($http_x_code = %d) {\n            return %d;\n        }\n", code, code)
+	}
+	config.WriteString("        return 404;\n    }\n}\n\n")
+	fmt.Fprintf(&config, "server {\n    listen %d;\n    root /etc/nginx/conf.d;\n", MAINTENANCE_BACKEND_PORT)
+	fmt.Fprintf(&config, "    error_page 503 /%s;\n", MaintenancePageKey)
+	fmt.Fprintf(&config, "    location = /%s {\n        internal;\n    }\n", MaintenancePageKey)
+	config.WriteString("    location / {\n        return 503;\n    }\n}\n")
+
+	return map[string]string{
+		ErrorPagesConfigKey: config.String(),
+		ErrorPageKey:        errorPage,
+		MaintenancePageKey:  maintenancePage,
+	}
+}
+
+// ErrorPagesHash fingerprints the error pages ConfigMap. nginx reads it at
+// startup, so the hash goes on the pod template of the error backend.
+func ErrorPagesHash(data map[string]string) string {
+	hash := sha256.New()
+	for _, key := range SortedKeys(data) {
+		hash.Write([]byte(key + "=" + data[key] + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// InMaintenance reports whether a frontend is served the maintenance page,
+// either on its own or because its whole tenant is.
+func InMaintenance(frontendPod *controllerapi.FrontendDeploy, ingressDeployment *controllerapi.SandOpsIngress) bool {
+	return frontendPod.Spec.Maintenance || (ingressDeployment != nil && ingressDeployment.Spec.Maintenance)
+}
@@ -1,7 +1,6 @@
 package utils
 
 const (
-	FOUND                              = "Found"
 	INGRESS_NGINX                      = "ingress-nginx"
 	INGRESS_NGINX_ADMISSION            = "ingress-nginx-admission"
 	CONTROLLER                         = "controller"
@@ -9,4 +8,40 @@ const (
 	INGRESS_NGINX_CONTROLLER           = "ingress-nginx-controller"
 	INGRESS_NGINX_CONTROLLER_ADMISSION = "ingress-nginx-controller-admission"
 	INGRESS_FINALIZER                  = "k8s.io/ingress-finalizer"
+	NGINX_RESTART_HASH_ANNOTATION      = "sandtech.io/nginx-config-restart-hash"
+	ADMISSION_CERT_HASH_ANNOTATION     = "sandtech.io/admission-cert-hash"
+	TENANT_NAME_LABEL                  = "sandtech.io/sandopsingress"
+	TENANT_NAMESPACE_LABEL             = "sandtech.io/sandopsingress-namespace"
+	FRONTEND_NAME_LABEL                = "sandtech.io/frontenddeploy"
+	PREVIEW_NAME_LABEL                 = "sandtech.io/frontendpreview"
+	APPLICATION_NAME_LABEL             = "sandtech.io/application"
+	REVISION_HASH_LABEL                = "sandtech.io/revision-hash"
+	ERROR_BACKEND                      = "error-backend"
+	MAINTENANCE_BACKEND                = "maintenance-backend"
+	ERROR_PAGES                        = "error-pages"
+	ERROR_PAGES_HASH_ANNOTATION        = "sandtech.io/error-pages-hash"
+	ACTIVATOR                          = "activator"
+	UPSTREAM_VHOST_ANNOTATION          = "nginx.ingress.kubernetes.io/upstream-vhost"
+	// DEFAULT_ACTIVATOR_SERVICE is the Service of the operator's activator
+	// as deployed by config/default.
+	DEFAULT_ACTIVATOR_SERVICE = "sand-ops-activator-service.sand-ops-system.svc.cluster.local"
+)
+
+const (
+	LIMIT_RPS_ANNOTATION              = "nginx.ingress.kubernetes.io/limit-rps"
+	LIMIT_BURST_MULTIPLIER_ANNOTATION = "nginx.ingress.kubernetes.io/limit-burst-multiplier"
+	LIMIT_CONNECTIONS_ANNOTATION      = "nginx.ingress.kubernetes.io/limit-connections"
+	LIMIT_WHITELIST_ANNOTATION        = "nginx.ingress.kubernetes.io/limit-whitelist"
+	DEFAULT_LIMIT_STATUS_CODE         = 429
+)
+
+const (
+	ENABLE_CORS_ANNOTATION            = "nginx.ingress.kubernetes.io/enable-cors"
+	CORS_ALLOW_ORIGIN_ANNOTATION      = "nginx.ingress.kubernetes.io/cors-allow-origin"
+	CORS_ALLOW_METHODS_ANNOTATION     = "nginx.ingress.kubernetes.io/cors-allow-methods"
+	CORS_ALLOW_HEADERS_ANNOTATION     = "nginx.ingress.kubernetes.io/cors-allow-headers"
+	CORS_EXPOSE_HEADERS_ANNOTATION    = "nginx.ingress.kubernetes.io/cors-expose-headers"
+	CORS_ALLOW_CREDENTIALS_ANNOTATION = "nginx.ingress.kubernetes.io/cors-allow-credentials"
+	CORS_MAX_AGE_ANNOTATION           = "nginx.ingress.kubernetes.io/cors-max-age"
+	CUSTOM_HEADERS_ANNOTATION         = "nginx.ingress.kubernetes.io/custom-headers"
 )
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -49,10 +52,33 @@ func NSSuffixedNamespace(name string) string {
 	return name + "-ns"
 }
 
+// maxObjectNameLength is the longest name the API server accepts for the
+// cluster-scoped kinds the operator creates.
+const maxObjectNameLength = 253
+
+// ClusterScopedName names a cluster-scoped object of the tenant behind a
+// SandOpsIngress, "<prefix>-<tenant namespace>". The tenant namespace is unique
+// and always ends in "-ns", so names of different tenants or of the shared
+// objects earlier versions created can't collide. Names over the limit are cut
+// and end in a hash of the full name instead.
+func ClusterScopedName(prefix, name string) string {
+	full := prefix + "-" + NSSuffixedNamespace(name)
+	if len(full) <= maxObjectNameLength {
+		return full
+	}
+	hash := sha256.Sum256([]byte(full))
+	suffix := hex.EncodeToString(hash[:])[:10]
+	return strings.TrimRight(full[:maxObjectNameLength-len(suffix)-1], "-.") + "-" + suffix
+}
+
+// IngressNamespacedName returns the SandOpsIngress that owns a tenant namespace.
+func IngressNamespacedName(namespace string) types.NamespacedName {
+	return types.NamespacedName{Name: strings.TrimSuffix(namespace, "-ns"), Namespace: namespace}
+}
+
 func GetIngress(namespace string, ctx context.Context, client client.Client) (*controllerapi.SandOpsIngress, error) {
-	ingressName := namespace[:len(namespace)-3]
 	ingress := &controllerapi.SandOpsIngress{}
-	err := client.Get(ctx, types.NamespacedName{Name: ingressName, Namespace: namespace}, ingress)
+	err := client.Get(ctx, IngressNamespacedName(namespace), ingress)
 	if err == nil {
 		return ingress, nil
 	}
@@ -68,3 +94,35 @@ func IngressPathExists(paths []networkingv1.HTTPIngressPath, targetPath string)
 	}
 	return false, nil, 0
 }
+
+func SharedIngressName(namespace string) string {
+	return namespace + "-ingress-service"
+}
+
+func FrontendIngressSuffixedString(name string) string {
+	return name + "-frontend-ingress"
+}
+
+func FrontendHeadersSuffixedString(name string) string {
+	return name + "-frontend-headers"
+}
+
+// PreviewFrontendName is the FrontendDeploy serving a FrontendPreview. The
+// suffix keeps previews out of the paths of regular frontends.
+func PreviewFrontendName(name string) string {
+	return name + "-preview"
+}
+
+// ApplicationFrontendName is the FrontendDeploy of a component of an
+// Application.
+func ApplicationFrontendName(application, component string) string {
+	return application + "-" + component
+}
+
+func TCPServicesConfigMapName(name string) string {
+	return NSSuffixedNamespace(name) + "-tcp-service-cm"
+}
+
+func UDPServicesConfigMapName(name string) string {
+	return NSSuffixedNamespace(name) + "-udp-service-cm"
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+var headerNameRegex = regexp.MustCompile(controllerapi.HTTPTokenPattern)
+
+// reservedResponseHeaders are set by nginx itself or by other annotations and
+// can't be overridden through ResponseHeaders.
+var reservedResponseHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Date":              true,
+	"Keep-Alive":        true,
+	"Server":            true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// ValidateResponseHeaders checks that every header is a valid HTTP field, is
+// not one nginx manages and is not a CORS header when the CORS policy owns it.
+func ValidateResponseHeaders(headers map[string]string, corsEnabled bool) error {
+	for _, name := range SortedKeys(headers) {
+		if !headerNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid response header name %q", name)
+		}
+		canonical := http.CanonicalHeaderKey(name)
+		if reservedResponseHeaders[canonical] {
+			return fmt.Errorf("response header %q is managed by nginx", name)
+		}
+		if corsEnabled && strings.HasPrefix(canonical, "Access-Control-") {
+			return fmt.Errorf("response header %q is managed by the cors policy", name)
+		}
+		if strings.ContainsAny(headers[name], "\r\n") {
+			return fmt.Errorf("response header %q has a multi-line value", name)
+		}
+	}
+	return nil
+}
+
+func SortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
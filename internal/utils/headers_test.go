@@ -0,0 +1,60 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+var _ = Describe("ValidateResponseHeaders", func() {
+	DescribeTable("accepts valid headers",
+		func(headers map[string]string, corsEnabled bool) {
+			Expect(ValidateResponseHeaders(headers, corsEnabled)).To(Succeed())
+		},
+		Entry("without headers", nil, false),
+		Entry("with security headers", map[string]string{
+			"Content-Security-Policy": "default-src 'self'",
+			"X-Frame-Options":         "DENY",
+		}, false),
+		Entry("with every tchar in the name", map[string]string{"X-!#$%&'*+.^_`|~": "1"}, false),
+		Entry("with CORS headers without a CORS policy", map[string]string{"Access-Control-Allow-Origin": "*"}, false),
+	)
+
+	DescribeTable("rejects invalid headers",
+		func(headers map[string]string, corsEnabled bool, message string) {
+			Expect(ValidateResponseHeaders(headers, corsEnabled)).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("with a space in the name", map[string]string{"X Frame": "DENY"}, false, "invalid response header name"),
+		Entry("with a colon in the name", map[string]string{"X-Frame:": "DENY"}, false, "invalid response header name"),
+		Entry("with a header nginx manages", map[string]string{"content-length": "10"}, false, "managed by nginx"),
+		Entry("with a CORS header next to a CORS policy", map[string]string{"access-control-allow-origin": "*"}, true, "managed by the cors policy"),
+		Entry("with a line break in the value", map[string]string{"X-Injected": "a\r\nSet-Cookie: b"}, false, "multi-line value"),
+	)
+
+	It("uses the token pattern of the CRD", func() {
+		crd, err := os.ReadFile("../../config/crd/bases/aasdev.sandtech.io_frontenddeploys.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		escaped := strings.ReplaceAll(controllerapi.HTTPTokenPattern, "'", `\'`)
+		Expect(string(crd)).To(ContainSubstring("k.matches('" + escaped + "')"))
+	})
+})
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	_ "time/tzdata" // schedules don't depend on the zoneinfo of the image
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+// Reasons of the Hibernated condition.
+const (
+	HibernatedBySpec     = "Hibernate"
+	HibernatedBySchedule = "Schedule"
+	HibernatedByTenant   = "TenantHibernated"
+	NotHibernated        = "Awake"
+)
+
+var weekdays = map[string]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// Hibernation is whether a frontend or tenant is hibernated at some instant,
+// and when that may change next.
+type Hibernation struct {
+	Hibernated bool
+	Reason     string
+	// Next is the next window start or end, zero without a schedule.
+	Next time.Time
+}
+
+// EvaluateHibernation works out whether spec.hibernate or a window of the
+// schedule hibernates at now. Windows are evaluated in the time zone of the
+// schedule, so they follow daylight saving time.
+func EvaluateHibernation(hibernate bool, schedule *controllerapi.HibernationSchedule, now time.Time) (Hibernation, error) {
+	state := Hibernation{Reason: NotHibernated}
+	if hibernate {
+		state = Hibernation{Hibernated: true, Reason: HibernatedBySpec}
+	}
+	if schedule == nil {
+		return state, nil
+	}
+
+	location, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		return state, fmt.Errorf("unknown time zone %q: %w", schedule.TimeZone, err)
+	}
+	local := now.In(location)
+	for _, window := range schedule.Windows {
+		startHour, startMinute, err := parseClock(window.Start)
+		if err != nil {
+			return state, err
+		}
+		endHour, endMinute, err := parseClock(window.End)
+		if err != nil {
+			return state, err
+		}
+		overnight := endHour*60+endMinute <= startHour*60+startMinute
+		// a window starting the day before may still be open, and the next
+		// start is at most a week away
+		for offset := -1; offset <= 7; offset++ {
+			day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, location)
+			if !slices.ContainsFunc(window.Days, func(name string) bool { return weekdays[name] == day.Weekday() }) {
+				continue
+			}
+			opens := time.Date(day.Year(), day.Month(), day.Day(), startHour, startMinute, 0, 0, location)
+			closes := time.Date(day.Year(), day.Month(), day.Day(), endHour, endMinute, 0, 0, location)
+			if overnight {
+				closes = closes.AddDate(0, 0, 1)
+			}
+			if !local.Before(opens) && local.Before(closes) && !state.Hibernated {
+				state.Hibernated, state.Reason = true, HibernatedBySchedule
+			}
+			for _, boundary := range []time.Time{opens, closes} {
+				if boundary.After(local) && (state.Next.IsZero() || boundary.Before(state.Next)) {
+					state.Next = boundary
+				}
+			}
+		}
+	}
+	return state, nil
+}
+
+// ValidateHibernationSchedule checks what the CRD schema can't, that the
+// time zone exists.
+func ValidateHibernationSchedule(schedule *controllerapi.HibernationSchedule) error {
+	_, err := EvaluateHibernation(false, schedule, time.Now())
+	return err
+}
+
+// parseClock splits HH:MM into hour and minute.
+func parseClock(clock string) (int, int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time of day %q, expected HH:MM", clock)
+	}
+	return parsed.Hour(), parsed.Minute(), nil
+}
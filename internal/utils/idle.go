@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+	"time"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+// DefaultWakeTimeout is how long the activator holds a request while a
+// frontend without wakeTimeoutSeconds scales up.
+const DefaultWakeTimeout = 2 * time.Minute
+
+// ActivatorVirtualHost is the Host the tenant ingress controller sends the
+// requests of an idle frontend to the activator with, naming the frontend.
+func ActivatorVirtualHost(frontendPod *controllerapi.FrontendDeploy) string {
+	return frontendPod.Name + "." + frontendPod.Namespace
+}
+
+// ParseActivatorVirtualHost returns the name and namespace of the frontend an
+// ActivatorVirtualHost names. Namespaces can't contain dots, so the name ends
+// at the last one.
+func ParseActivatorVirtualHost(host string) (string, string, bool) {
+	if i := strings.IndexByte(host, ':'); i >= 0 {
+		host = host[:i]
+	}
+	i := strings.LastIndexByte(host, '.')
+	if i <= 0 || i == len(host)-1 {
+		return "", "", false
+	}
+	return host[:i], host[i+1:], true
+}
+
+// WakeTimeout is how long the activator holds a request to the frontend.
+func WakeTimeout(frontendPod *controllerapi.FrontendDeploy) time.Duration {
+	if policy := frontendPod.Spec.IdlePolicy; policy != nil && policy.WakeTimeoutSeconds > 0 {
+		return time.Duration(policy.WakeTimeoutSeconds) * time.Second
+	}
+	return DefaultWakeTimeout
+}
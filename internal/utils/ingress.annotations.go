@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+// RateLimitAnnotations renders the limits into ingress-nginx annotations.
+// Fields of override that are set win over the same fields of defaults.
+func RateLimitAnnotations(defaults *controllerapi.RateLimit, override *controllerapi.RateLimit) map[string]string {
+	annotations := map[string]string{}
+	for _, rateLimit := range []*controllerapi.RateLimit{defaults, override} {
+		if rateLimit == nil {
+			continue
+		}
+		if rateLimit.RequestsPerSecond > 0 {
+			annotations[LIMIT_RPS_ANNOTATION] = strconv.Itoa(int(rateLimit.RequestsPerSecond))
+		}
+		if rateLimit.BurstMultiplier > 0 {
+			annotations[LIMIT_BURST_MULTIPLIER_ANNOTATION] = strconv.Itoa(int(rateLimit.BurstMultiplier))
+		}
+		if rateLimit.Connections > 0 {
+			annotations[LIMIT_CONNECTIONS_ANNOTATION] = strconv.Itoa(int(rateLimit.Connections))
+		}
+		if len(rateLimit.WhitelistCIDRs) > 0 {
+			annotations[LIMIT_WHITELIST_ANNOTATION] = strings.Join(rateLimit.WhitelistCIDRs, ",")
+		}
+	}
+	return annotations
+}
+
+// TenantRateLimit returns the tenant defaults of a SandOpsIngress, or nil.
+func TenantRateLimit(ingress *controllerapi.SandOpsIngress) *controllerapi.RateLimit {
+	if ingress == nil || ingress.Spec.RateLimit == nil {
+		return nil
+	}
+	return &ingress.Spec.RateLimit.RateLimit
+}
+
+// CORSAnnotations renders a CORS policy into ingress-nginx annotations.
+func CORSAnnotations(cors *controllerapi.CORSPolicy) map[string]string {
+	annotations := map[string]string{}
+	if cors == nil {
+		return annotations
+	}
+
+	annotations[ENABLE_CORS_ANNOTATION] = "true"
+	annotations[CORS_ALLOW_CREDENTIALS_ANNOTATION] = strconv.FormatBool(cors.AllowCredentials)
+	if len(cors.AllowOrigins) > 0 {
+		annotations[CORS_ALLOW_ORIGIN_ANNOTATION] = strings.Join(cors.AllowOrigins, ", ")
+	}
+	if len(cors.AllowMethods) > 0 {
+		annotations[CORS_ALLOW_METHODS_ANNOTATION] = strings.Join(cors.AllowMethods, ", ")
+	}
+	if len(cors.AllowHeaders) > 0 {
+		annotations[CORS_ALLOW_HEADERS_ANNOTATION] = strings.Join(cors.AllowHeaders, ", ")
+	}
+	if len(cors.ExposeHeaders) > 0 {
+		annotations[CORS_EXPOSE_HEADERS_ANNOTATION] = strings.Join(cors.ExposeHeaders, ", ")
+	}
+	if cors.MaxAge > 0 {
+		annotations[CORS_MAX_AGE_ANNOTATION] = strconv.Itoa(int(cors.MaxAge))
+	}
+	return annotations
+}
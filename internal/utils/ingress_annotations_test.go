@@ -0,0 +1,103 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+var _ = Describe("RateLimitAnnotations", func() {
+	DescribeTable("renders tenant defaults and frontend overrides",
+		func(defaults, override *controllerapi.RateLimit, expected map[string]string) {
+			Expect(RateLimitAnnotations(defaults, override)).To(Equal(expected))
+		},
+		Entry("without limits", nil, nil, map[string]string{}),
+		Entry("with tenant defaults only",
+			&controllerapi.RateLimit{RequestsPerSecond: 10, BurstMultiplier: 3, Connections: 5, WhitelistCIDRs: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+			nil,
+			map[string]string{
+				LIMIT_RPS_ANNOTATION:              "10",
+				LIMIT_BURST_MULTIPLIER_ANNOTATION: "3",
+				LIMIT_CONNECTIONS_ANNOTATION:      "5",
+				LIMIT_WHITELIST_ANNOTATION:        "10.0.0.0/8,192.168.0.0/16",
+			}),
+		Entry("with frontend limits only",
+			nil,
+			&controllerapi.RateLimit{RequestsPerSecond: 20},
+			map[string]string{LIMIT_RPS_ANNOTATION: "20"}),
+		Entry("with frontend fields winning over the tenant defaults",
+			&controllerapi.RateLimit{RequestsPerSecond: 10, Connections: 5, WhitelistCIDRs: []string{"10.0.0.0/8"}},
+			&controllerapi.RateLimit{RequestsPerSecond: 50, WhitelistCIDRs: []string{"172.16.0.0/12"}},
+			map[string]string{
+				LIMIT_RPS_ANNOTATION:         "50",
+				LIMIT_CONNECTIONS_ANNOTATION: "5",
+				LIMIT_WHITELIST_ANNOTATION:   "172.16.0.0/12",
+			}),
+		Entry("with unset frontend fields falling back to the tenant defaults",
+			&controllerapi.RateLimit{RequestsPerSecond: 10, BurstMultiplier: 2},
+			&controllerapi.RateLimit{},
+			map[string]string{
+				LIMIT_RPS_ANNOTATION:              "10",
+				LIMIT_BURST_MULTIPLIER_ANNOTATION: "2",
+			}),
+	)
+
+	It("reads the tenant defaults of a SandOpsIngress", func() {
+		Expect(TenantRateLimit(nil)).To(BeNil())
+		Expect(TenantRateLimit(&controllerapi.SandOpsIngress{})).To(BeNil())
+
+		tenant := &controllerapi.SandOpsIngress{Spec: controllerapi.SandOpsIngressSpec{
+			RateLimit: &controllerapi.TenantRateLimit{RateLimit: controllerapi.RateLimit{RequestsPerSecond: 7}},
+		}}
+		Expect(TenantRateLimit(tenant).RequestsPerSecond).To(Equal(int32(7)))
+	})
+})
+
+var _ = Describe("CORSAnnotations", func() {
+	It("renders nothing without a policy", func() {
+		Expect(CORSAnnotations(nil)).To(BeEmpty())
+	})
+
+	It("enables CORS with the nginx defaults for an empty policy", func() {
+		Expect(CORSAnnotations(&controllerapi.CORSPolicy{})).To(Equal(map[string]string{
+			ENABLE_CORS_ANNOTATION:            "true",
+			CORS_ALLOW_CREDENTIALS_ANNOTATION: "false",
+		}))
+	})
+
+	It("renders every field of the policy", func() {
+		Expect(CORSAnnotations(&controllerapi.CORSPolicy{
+			AllowOrigins:     []string{"https://a.example.com", "https://b.example.com"},
+			AllowMethods:     []string{"GET", "POST"},
+			AllowHeaders:     []string{"Authorization", "Content-Type"},
+			ExposeHeaders:    []string{"X-Request-Id"},
+			AllowCredentials: true,
+			MaxAge:           600,
+		})).To(Equal(map[string]string{
+			ENABLE_CORS_ANNOTATION:            "true",
+			CORS_ALLOW_ORIGIN_ANNOTATION:      "https://a.example.com, https://b.example.com",
+			CORS_ALLOW_METHODS_ANNOTATION:     "GET, POST",
+			CORS_ALLOW_HEADERS_ANNOTATION:     "Authorization, Content-Type",
+			CORS_EXPOSE_HEADERS_ANNOTATION:    "X-Request-Id",
+			CORS_ALLOW_CREDENTIALS_ANNOTATION: "true",
+			CORS_MAX_AGE_ANNOTATION:           "600",
+		}))
+	})
+})
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+// ConfigMapSnippetKeys inject raw nginx configuration through the ConfigMap,
+// so they follow the tenant's snippet policy just like the annotations.
+var ConfigMapSnippetKeys = []string{
+	"main-snippet",
+	"http-snippet",
+	"server-snippet",
+	"location-snippet",
+	"stream-snippet",
+	"global-auth-snippet",
+	MODSECURITY_SNIPPET_KEY,
+}
+
+// MODSECURITY_SNIPPET_KEY holds ModSecurity rules rather than nginx
+// configuration, like MODSECURITY_SNIPPET_ANNOTATION.
+const MODSECURITY_SNIPPET_KEY = "modsecurity-snippet"
+
+// ValidateConfigMapSnippets checks the snippet keys of spec.nginxConfig against
+// the tenant's allowed directives. They are dropped when snippets are
+// disabled, so there is nothing to check then.
+func ValidateConfigMapSnippets(ingress *controllerapi.SandOpsIngress) error {
+	if !SnippetsEnabled(ingress) {
+		return nil
+	}
+	allowed := AllowedSnippetDirectives(ingress)
+	for _, key := range ConfigMapSnippetKeys {
+		snippet, ok := ingress.Spec.NginxConfig[key]
+		if !ok {
+			continue
+		}
+		var err error
+		if key == MODSECURITY_SNIPPET_KEY {
+			err = validateDirectives(ModSecurityDirectives(snippet), allowed)
+		} else {
+			err = ValidateSnippet(snippet, allowed)
+		}
+		if err != nil {
+			return fmt.Errorf("spec.nginxConfig[%s]: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// RestartConfigMapKeys only take effect when the nginx master process starts,
+// a reload keeps the old value: the sizes of the shared memory zones and the
+// options of the listening sockets. Worker settings such as worker-processes
+// are picked up by the new workers of a reload.
+var RestartConfigMapKeys = []string{
+	"lua-shared-dicts",
+	"reuse-port",
+}
+
+// NginxSettingsData renders the typed settings into ConfigMap keys.
+func NginxSettingsData(settings *controllerapi.NginxSettings) map[string]string {
+	data := map[string]string{}
+	if settings == nil {
+		return data
+	}
+
+	if settings.LogFormat != "" {
+		data["log-format-upstream"] = settings.LogFormat
+	}
+	if settings.UseForwardedHeaders != nil {
+		data["use-forwarded-headers"] = strconv.FormatBool(*settings.UseForwardedHeaders)
+	}
+	if len(settings.ProxyRealIPCIDRs) > 0 {
+		data["proxy-real-ip-cidr"] = strings.Join(settings.ProxyRealIPCIDRs, ",")
+	}
+	if settings.Gzip != nil {
+		data["use-gzip"] = strconv.FormatBool(*settings.Gzip)
+	}
+	if settings.Brotli != nil {
+		data["enable-brotli"] = strconv.FormatBool(*settings.Brotli)
+	}
+	if len(settings.SSLProtocols) > 0 {
+		data["ssl-protocols"] = strings.Join(settings.SSLProtocols, " ")
+	}
+	if settings.WorkerProcesses != "" {
+		data["worker-processes"] = settings.WorkerProcesses
+	}
+	if settings.MaxWorkerConnections > 0 {
+		data["max-worker-connections"] = strconv.Itoa(int(settings.MaxWorkerConnections))
+	}
+	if settings.WorkerShutdownTimeout != "" {
+		data["worker-shutdown-timeout"] = settings.WorkerShutdownTimeout
+	}
+	return data
+}
+
+// RestartHash fingerprints the ConfigMap keys that need a controller restart.
+// It goes on the pod template, so a change rolls the ingress controller.
+func RestartHash(data map[string]string) string {
+	hash := sha256.New()
+	for _, key := range RestartConfigMapKeys {
+		if value, ok := data[key]; ok {
+			hash.Write([]byte(key + "=" + value + "\n"))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
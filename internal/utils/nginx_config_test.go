@@ -0,0 +1,116 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+var _ = Describe("NginxSettingsData", func() {
+	It("renders nothing without settings", func() {
+		Expect(NginxSettingsData(nil)).To(BeEmpty())
+		Expect(NginxSettingsData(&controllerapi.NginxSettings{})).To(BeEmpty())
+	})
+
+	It("renders every setting into its ConfigMap key", func() {
+		enabled, disabled := true, false
+		Expect(NginxSettingsData(&controllerapi.NginxSettings{
+			LogFormat:             "$remote_addr $status",
+			UseForwardedHeaders:   &enabled,
+			ProxyRealIPCIDRs:      []string{"10.0.0.0/8", "192.168.0.0/16"},
+			Gzip:                  &disabled,
+			Brotli:                &enabled,
+			SSLProtocols:          []string{"TLSv1.2", "TLSv1.3"},
+			WorkerProcesses:       "4",
+			MaxWorkerConnections:  8192,
+			WorkerShutdownTimeout: "30s",
+		})).To(Equal(map[string]string{
+			"log-format-upstream":     "$remote_addr $status",
+			"use-forwarded-headers":   "true",
+			"proxy-real-ip-cidr":      "10.0.0.0/8,192.168.0.0/16",
+			"use-gzip":                "false",
+			"enable-brotli":           "true",
+			"ssl-protocols":           "TLSv1.2 TLSv1.3",
+			"worker-processes":        "4",
+			"max-worker-connections":  "8192",
+			"worker-shutdown-timeout": "30s",
+		}))
+	})
+})
+
+var _ = Describe("RestartHash", func() {
+	data := map[string]string{"reuse-port": "true", "use-gzip": "true"}
+
+	It("is stable", func() {
+		Expect(RestartHash(data)).To(Equal(RestartHash(map[string]string{"use-gzip": "true", "reuse-port": "true"})))
+		Expect(RestartHash(data)).To(HaveLen(16))
+	})
+
+	It("ignores keys a reload applies", func() {
+		Expect(RestartHash(data)).To(Equal(RestartHash(map[string]string{"reuse-port": "true", "use-gzip": "false", "http-snippet": "expires 1h;"})))
+		Expect(RestartHash(data)).To(Equal(RestartHash(map[string]string{"reuse-port": "true", "use-gzip": "true", "worker-processes": "8"})))
+	})
+
+	It("changes with the keys that need a restart", func() {
+		Expect(RestartHash(data)).NotTo(Equal(RestartHash(map[string]string{"reuse-port": "false", "use-gzip": "true"})))
+		Expect(RestartHash(data)).NotTo(Equal(RestartHash(map[string]string{"reuse-port": "true", "use-gzip": "true", "lua-shared-dicts": "configuration_data: 20"})))
+	})
+})
+
+var _ = Describe("ValidateConfigMapSnippets", func() {
+	tenant := func(snippets *controllerapi.SnippetPolicy, config map[string]string) *controllerapi.SandOpsIngress {
+		return &controllerapi.SandOpsIngress{Spec: controllerapi.SandOpsIngressSpec{Snippets: snippets, NginxConfig: config}}
+	}
+
+	It("skips the snippet keys of a tenant without snippets, they are dropped", func() {
+		Expect(ValidateConfigMapSnippets(tenant(nil, map[string]string{"http-snippet": "lua_shared_dict x 1m;"}))).To(Succeed())
+	})
+
+	It("accepts snippet keys with allowed directives", func() {
+		Expect(ValidateConfigMapSnippets(tenant(&controllerapi.SnippetPolicy{Enabled: true}, map[string]string{
+			"server-snippet":  "expires 1h;",
+			"proxy-body-size": "8m",
+		}))).To(Succeed())
+	})
+
+	It("rejects a snippet key with a directive outside the allow list", func() {
+		err := ValidateConfigMapSnippets(tenant(&controllerapi.SnippetPolicy{Enabled: true, AllowedDirectives: []string{"expires"}}, map[string]string{
+			"server-snippet": "expires 1h;",
+			"main-snippet":   "load_module /tmp/evil.so;",
+		}))
+		Expect(err).To(MatchError(ContainSubstring("spec.nginxConfig[main-snippet]")))
+		Expect(err).To(MatchError(ContainSubstring(`"load_module"`)))
+	})
+
+	It("checks global-auth-snippet as nginx configuration", func() {
+		err := ValidateConfigMapSnippets(tenant(&controllerapi.SnippetPolicy{Enabled: true}, map[string]string{
+			"global-auth-snippet": "access_by_lua_block { os.execute('id') }",
+		}))
+		Expect(err).To(MatchError(ContainSubstring("spec.nginxConfig[global-auth-snippet]")))
+	})
+
+	It("checks modsecurity-snippet as ModSecurity rules", func() {
+		policy := &controllerapi.SnippetPolicy{Enabled: true, AllowedDirectives: []string{"SecRuleEngine"}}
+		Expect(ValidateConfigMapSnippets(tenant(policy, map[string]string{"modsecurity-snippet": "SecRuleEngine On"}))).To(Succeed())
+		err := ValidateConfigMapSnippets(tenant(policy, map[string]string{"modsecurity-snippet": "SecRuleEngine On\nInclude /etc/passwd"}))
+		Expect(err).To(MatchError(ContainSubstring("spec.nginxConfig[modsecurity-snippet]")))
+		Expect(err).To(MatchError(ContainSubstring(`"Include"`)))
+	})
+})
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+// PortServicesData renders the tcp/udp services ConfigMap read by
+// ingress-nginx: "<port>": "<namespace>/<service>:<port>[:PROXY[:PROXY]]".
+func PortServicesData(tenantNamespace string, services []controllerapi.PortService, protocol corev1.Protocol) map[string]string {
+	data := map[string]string{}
+	for _, service := range services {
+		namespace := service.ServiceNamespace
+		if namespace == "" {
+			namespace = tenantNamespace
+		}
+		value := fmt.Sprintf("%s/%s:%d", namespace, service.ServiceName, service.ServicePort)
+		if service.ProxyProtocol && protocol == corev1.ProtocolTCP {
+			value += ":PROXY:PROXY"
+		}
+		data[strconv.Itoa(int(service.Port))] = value
+	}
+	return data
+}
+
+// ReservedIngressPorts are the ports ingress-nginx listens on itself: http,
+// https, the default server, the admission webhook, the profiler, status and
+// stream ports, and health and metrics. The CRD rejects them too, but lets
+// mappings made before a port was reserved through.
+var ReservedIngressPorts = []int32{80, 443, 8181, 8443, 10245, 10246, 10247, 10254}
+
+// ValidatePortServices checks that every mapping points into the tenant
+// namespace and leaves the ReservedIngressPorts alone. The ingress controller
+// reaches Services in any namespace, so a mapping elsewhere would expose a
+// Service the tenant doesn't own.
+func ValidatePortServices(ingress *controllerapi.SandOpsIngress) error {
+	if err := validatePortServices("tcpServices", ingress.Spec.TCPServices, NSSuffixedNamespace(ingress.Name)); err != nil {
+		return err
+	}
+	return validatePortServices("udpServices", ingress.Spec.UDPServices, NSSuffixedNamespace(ingress.Name))
+}
+
+func validatePortServices(field string, services []controllerapi.PortService, tenantNamespace string) error {
+	for _, service := range services {
+		if slices.Contains(ReservedIngressPorts, service.Port) {
+			return fmt.Errorf("spec.%s: port %d is used by the ingress controller", field, service.Port)
+		}
+		if service.ServiceNamespace != "" && service.ServiceNamespace != tenantNamespace {
+			return fmt.Errorf("spec.%s: port %d: serviceNamespace must be the tenant namespace %s", field, service.Port, tenantNamespace)
+		}
+	}
+	return nil
+}
+
+// PortServiceName names the Service and container port opened for a mapping.
+func PortServiceName(protocol corev1.Protocol, port int32) string {
+	if protocol == corev1.ProtocolUDP {
+		return fmt.Sprintf("udp-%d", port)
+	}
+	return fmt.Sprintf("tcp-%d", port)
+}
+
+// IngressServicePorts are the ports of the tenant LoadBalancer Service.
+func IngressServicePorts(ingress *controllerapi.SandOpsIngress) []corev1.ServicePort {
+	ports := []corev1.ServicePort{
+		{
+			AppProtocol: DataTypePointerRef("http"),
+			Name:        "http",
+			Port:        80,
+			Protocol:    corev1.ProtocolTCP,
+			TargetPort:  intstr.FromString("http"),
+		},
+		{
+			AppProtocol: DataTypePointerRef("https"),
+			Name:        "https",
+			Port:        443,
+			Protocol:    corev1.ProtocolTCP,
+			TargetPort:  intstr.FromString("https"),
+		},
+	}
+	for _, service := range ingress.Spec.TCPServices {
+		ports = append(ports, portServicePort(corev1.ProtocolTCP, service.Port))
+	}
+	for _, service := range ingress.Spec.UDPServices {
+		ports = append(ports, portServicePort(corev1.ProtocolUDP, service.Port))
+	}
+	return ports
+}
+
+func portServicePort(protocol corev1.Protocol, port int32) corev1.ServicePort {
+	return corev1.ServicePort{
+		Name:       PortServiceName(protocol, port),
+		Port:       port,
+		Protocol:   protocol,
+		TargetPort: intstr.FromString(PortServiceName(protocol, port)),
+	}
+}
+
+// IngressContainerPorts are the ports of the ingress controller container.
+func IngressContainerPorts(ingress *controllerapi.SandOpsIngress) []corev1.ContainerPort {
+	ports := []corev1.ContainerPort{
+		{
+			ContainerPort: 80,
+			Name:          "http",
+			Protocol:      corev1.ProtocolTCP,
+		},
+		{
+			ContainerPort: 443,
+			Name:          "https",
+			Protocol:      corev1.ProtocolTCP,
+		},
+		{
+			ContainerPort: 8443,
+			Name:          "webhook",
+			Protocol:      corev1.ProtocolTCP,
+		},
+	}
+	for _, service := range ingress.Spec.TCPServices {
+		ports = append(ports, corev1.ContainerPort{
+			ContainerPort: service.Port,
+			Name:          PortServiceName(corev1.ProtocolTCP, service.Port),
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+	for _, service := range ingress.Spec.UDPServices {
+		ports = append(ports, corev1.ContainerPort{
+			ContainerPort: service.Port,
+			Name:          PortServiceName(corev1.ProtocolUDP, service.Port),
+			Protocol:      corev1.ProtocolUDP,
+		})
+	}
+	return ports
+}
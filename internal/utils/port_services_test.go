@@ -0,0 +1,109 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+var _ = Describe("PortServicesData", func() {
+	services := []controllerapi.PortService{
+		{Port: 5432, ServiceName: "postgres", ServicePort: 5432},
+		{Port: 1883, ServiceName: "mqtt", ServiceNamespace: "tenant-ns", ServicePort: 1884, ProxyProtocol: true},
+	}
+
+	It("maps every port to a Service of the tenant namespace", func() {
+		Expect(PortServicesData("tenant-ns", services, corev1.ProtocolTCP)).To(Equal(map[string]string{
+			"5432": "tenant-ns/postgres:5432",
+			"1883": "tenant-ns/mqtt:1884:PROXY:PROXY",
+		}))
+	})
+
+	It("doesn't speak the PROXY protocol over UDP", func() {
+		Expect(PortServicesData("tenant-ns", services, corev1.ProtocolUDP)).To(HaveKeyWithValue("1883", "tenant-ns/mqtt:1884"))
+	})
+})
+
+var _ = Describe("IngressServicePorts", func() {
+	It("opens the mapped ports next to http and https", func() {
+		ingress := &controllerapi.SandOpsIngress{Spec: controllerapi.SandOpsIngressSpec{
+			TCPServices: []controllerapi.PortService{{Port: 5432, ServiceName: "postgres", ServicePort: 5432}},
+			UDPServices: []controllerapi.PortService{{Port: 53, ServiceName: "dns", ServicePort: 53}},
+		}}
+		ports := IngressServicePorts(ingress)
+		Expect(ports).To(HaveLen(4))
+		Expect(ports[2].Name).To(Equal("tcp-5432"))
+		Expect(ports[2].TargetPort.StrVal).To(Equal("tcp-5432"))
+		Expect(ports[3].Name).To(Equal("udp-53"))
+		Expect(ports[3].Protocol).To(Equal(corev1.ProtocolUDP))
+
+		containerPorts := IngressContainerPorts(ingress)
+		Expect(containerPorts).To(ContainElement(corev1.ContainerPort{Name: "udp-53", ContainerPort: 53, Protocol: corev1.ProtocolUDP}))
+	})
+})
+
+var _ = Describe("ValidatePortServices", func() {
+	tenant := func(tcpNamespace, udpNamespace string) *controllerapi.SandOpsIngress {
+		return &controllerapi.SandOpsIngress{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "tenant-ns"},
+			Spec: controllerapi.SandOpsIngressSpec{
+				TCPServices: []controllerapi.PortService{{Port: 5432, ServiceName: "postgres", ServiceNamespace: tcpNamespace, ServicePort: 5432}},
+				UDPServices: []controllerapi.PortService{{Port: 53, ServiceName: "dns", ServiceNamespace: udpNamespace, ServicePort: 53}},
+			},
+		}
+	}
+
+	It("accepts Services of the tenant namespace", func() {
+		Expect(ValidatePortServices(tenant("", "tenant-ns"))).To(Succeed())
+	})
+
+	It("rejects a Service in another namespace", func() {
+		Expect(ValidatePortServices(tenant("kube-system", ""))).To(MatchError(ContainSubstring("spec.tcpServices: port 5432")))
+		Expect(ValidatePortServices(tenant("", "kube-system"))).To(MatchError(ContainSubstring("spec.udpServices: port 53")))
+	})
+
+	DescribeTable("rejects a port the ingress controller listens on",
+		func(port int32) {
+			ingress := tenant("", "")
+			ingress.Spec.TCPServices[0].Port = port
+			Expect(ValidatePortServices(ingress)).To(MatchError(ContainSubstring("is used by the ingress controller")))
+		},
+		Entry("the https port", int32(443)),
+		Entry("the default server port", int32(8181)),
+		Entry("the status port", int32(10246)),
+		Entry("the stream port", int32(10247)),
+	)
+
+	It("reserves the ports of the CRD rule", func() {
+		crd, err := os.ReadFile("../../config/crd/bases/aasdev.sandtech.io_sandopsingresses.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		ports := make([]string, 0, len(ReservedIngressPorts))
+		for _, port := range ReservedIngressPorts {
+			ports = append(ports, strconv.Itoa(int(port)))
+		}
+		Expect(string(crd)).To(ContainSubstring("!(self.port in [" + strings.Join(ports, ", ") + "])"))
+	})
+})
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+)
+
+// SnippetAnnotations are the ingress-nginx annotations that inject raw nginx
+// configuration into the tenant controller.
+var SnippetAnnotations = []string{
+	"nginx.ingress.kubernetes.io/configuration-snippet",
+	"nginx.ingress.kubernetes.io/server-snippet",
+	"nginx.ingress.kubernetes.io/auth-snippet",
+	"nginx.ingress.kubernetes.io/stream-snippet",
+	MODSECURITY_SNIPPET_ANNOTATION,
+}
+
+// MODSECURITY_SNIPPET_ANNOTATION holds ModSecurity rules rather than nginx
+// configuration, one directive per line.
+const MODSECURITY_SNIPPET_ANNOTATION = "nginx.ingress.kubernetes.io/modsecurity-snippet"
+
+// DefaultSnippetDirectives is used when a tenant enables snippets without
+// listing the directives it needs.
+var DefaultSnippetDirectives = []string{
+	"add_header",
+	"more_set_headers",
+	"more_clear_headers",
+	"proxy_set_header",
+	"proxy_hide_header",
+	"expires",
+}
+
+func SnippetsEnabled(ingress *controllerapi.SandOpsIngress) bool {
+	return ingress.Spec.Snippets != nil && ingress.Spec.Snippets.Enabled
+}
+
+func AllowedSnippetDirectives(ingress *controllerapi.SandOpsIngress) []string {
+	if ingress.Spec.Snippets == nil || len(ingress.Spec.Snippets.AllowedDirectives) == 0 {
+		return DefaultSnippetDirectives
+	}
+	return ingress.Spec.Snippets.AllowedDirectives
+}
+
+// SnippetDirectives returns the directive of every statement in an nginx
+// snippet, including statements nested in blocks. It tokenizes the snippet
+// the way nginx's ngx_conf_read_token does: a comment or a quoted string only
+// opens at the start of a token, and a backslash escapes the next character.
+func SnippetDirectives(snippet string) ([]string, error) {
+	directives := []string{}
+	args := []string{}
+	token := strings.Builder{}
+	var quote rune
+	inToken, escaped, variable, needSpace, comment := false, false, false, false, false
+
+	endToken := func() {
+		args = append(args, token.String())
+		token.Reset()
+		inToken = false
+	}
+	endStatement := func(c rune) error {
+		if len(args) == 0 {
+			return fmt.Errorf("unexpected %q", c)
+		}
+		directives = append(directives, args[0])
+		args = args[:0]
+		return nil
+	}
+	isSpace := func(c rune) bool {
+		return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+	}
+
+	for _, c := range snippet {
+		switch {
+		case comment:
+			if c == '\n' {
+				comment = false
+			}
+		case escaped:
+			escaped = false
+			switch c {
+			case '"', '\'', '\\':
+				token.WriteRune(c)
+			case 't':
+				token.WriteRune('\t')
+			case 'r':
+				token.WriteRune('\r')
+			case 'n':
+				token.WriteRune('\n')
+			default:
+				token.WriteRune('\\')
+				token.WriteRune(c)
+			}
+		case needSpace:
+			needSpace = false
+			switch {
+			case isSpace(c), c == ')':
+			case c == ';', c == '{':
+				if err := endStatement(c); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("unexpected %q after a quoted string", c)
+			}
+		case quote != 0:
+			switch c {
+			case '\\':
+				escaped = true
+			case quote:
+				quote = 0
+				needSpace = true
+				endToken()
+			default:
+				token.WriteRune(c)
+			}
+		case inToken:
+			if c == '{' && variable {
+				token.WriteRune(c)
+				continue
+			}
+			variable = false
+			switch {
+			case c == '\\':
+				escaped = true
+			case c == '$':
+				variable = true
+				token.WriteRune(c)
+			case isSpace(c):
+				endToken()
+			case c == ';', c == '{':
+				endToken()
+				if err := endStatement(c); err != nil {
+					return nil, err
+				}
+			default:
+				token.WriteRune(c)
+			}
+		case isSpace(c):
+		case c == ';', c == '{':
+			if err := endStatement(c); err != nil {
+				return nil, err
+			}
+		case c == '}':
+			if len(args) > 0 {
+				return nil, fmt.Errorf("unexpected '}'")
+			}
+		case c == '#':
+			comment = true
+		case c == '"', c == '\'':
+			quote = c
+			inToken = true
+		default:
+			inToken = true
+			variable = c == '$'
+			if c == '\\' {
+				escaped = true
+			} else {
+				token.WriteRune(c)
+			}
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quoted string")
+	}
+	if escaped || inToken || len(args) > 0 {
+		if inToken {
+			endToken()
+		}
+		return nil, fmt.Errorf("statement %q is missing a terminating ';'", strings.Join(args, " "))
+	}
+	return directives, nil
+}
+
+// ModSecurityDirectives returns the directive of every rule in a ModSecurity
+// snippet. A line ending in a backslash continues on the next one.
+func ModSecurityDirectives(snippet string) []string {
+	directives := []string{}
+	rule := strings.Builder{}
+	for _, line := range strings.Split(snippet, "\n") {
+		line = strings.TrimSpace(line)
+		if continued, ok := strings.CutSuffix(line, "\\"); ok {
+			rule.WriteString(continued + " ")
+			continue
+		}
+		rule.WriteString(line)
+		fields := strings.Fields(rule.String())
+		rule.Reset()
+		if len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			directives = append(directives, fields[0])
+		}
+	}
+	return directives
+}
+
+// ValidateSnippet checks that a snippet only uses allowed directives.
+func ValidateSnippet(snippet string, allowed []string) error {
+	directives, err := SnippetDirectives(snippet)
+	if err != nil {
+		return err
+	}
+	return validateDirectives(directives, allowed)
+}
+
+// ValidateAnnotationSnippet checks the snippet of one of SnippetAnnotations,
+// parsing it the way ingress-nginx uses it.
+func ValidateAnnotationSnippet(annotation, snippet string, allowed []string) error {
+	if annotation == MODSECURITY_SNIPPET_ANNOTATION {
+		return validateDirectives(ModSecurityDirectives(snippet), allowed)
+	}
+	return ValidateSnippet(snippet, allowed)
+}
+
+func validateDirectives(directives, allowed []string) error {
+	allowedSet := map[string]bool{}
+	for _, directive := range allowed {
+		allowedSet[directive] = true
+	}
+	for _, directive := range directives {
+		if !allowedSet[directive] {
+			return fmt.Errorf("directive %q is not allowed", directive)
+		}
+	}
+	return nil
+}
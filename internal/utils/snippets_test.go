@@ -0,0 +1,84 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateAnnotationSnippet", func() {
+	DescribeTable("finds the directives of a ModSecurity snippet",
+		func(snippet string, directives []string) {
+			Expect(ModSecurityDirectives(snippet)).To(Equal(directives))
+		},
+		Entry("one per line", "SecRuleEngine On\nSecAuditEngine Off\n", []string{"SecRuleEngine", "SecAuditEngine"}),
+		Entry("skipping comments and blank lines", "# engine\n\nSecRuleEngine On", []string{"SecRuleEngine"}),
+		Entry("joining continued lines", "SecRule ARGS \"@rx x\" \\\n  \"id:1,deny\"\nSecRuleEngine On", []string{"SecRule", "SecRuleEngine"}),
+		Entry("starting on a continued line", "\\\nInclude /etc/passwd", []string{"Include"}),
+	)
+
+	It("checks ModSecurity snippets by line and nginx snippets by statement", func() {
+		allowed := []string{"SecRuleEngine", "add_header"}
+		Expect(ValidateAnnotationSnippet(MODSECURITY_SNIPPET_ANNOTATION, "SecRuleEngine On", allowed)).To(Succeed())
+		Expect(ValidateAnnotationSnippet(MODSECURITY_SNIPPET_ANNOTATION, "SecRuleEngine On\nInclude /etc/passwd", allowed)).
+			To(MatchError(ContainSubstring(`"Include"`)))
+		Expect(ValidateAnnotationSnippet("nginx.ingress.kubernetes.io/server-snippet", "add_header X-A a;", allowed)).To(Succeed())
+		Expect(ValidateAnnotationSnippet("nginx.ingress.kubernetes.io/server-snippet", "add_header X-A a", allowed)).
+			To(MatchError(ContainSubstring("terminating ';'")))
+	})
+})
+
+var _ = Describe("ValidateSnippet", func() {
+	allowed := []string{"add_header", "location"}
+
+	DescribeTable("finds the directives of an nginx snippet",
+		func(snippet string, directives []string) {
+			Expect(SnippetDirectives(snippet)).To(Equal(directives))
+		},
+		Entry("one per statement", "add_header X-A a;\nexpires 1h;", []string{"add_header", "expires"}),
+		Entry("nested in blocks", "location /a { add_header X-A a; }", []string{"location", "add_header"}),
+		Entry("skipping comments", "# proxy_pass x;\nadd_header X-A a; # proxy_pass y;", []string{"add_header"}),
+		Entry("with quoted arguments", `add_header X-A "a; b" always;`, []string{"add_header"}),
+		Entry("with a quoted directive", `"proxy_pass" http://evil;`, []string{"proxy_pass"}),
+		Entry("with escaped quotes", `add_header X-A "a\" b";`, []string{"add_header"}),
+		Entry("with variables in braces", "add_header X-A ${host}a;", []string{"add_header"}),
+		Entry("with a '#' inside a token", "add_header X a#;\nproxy_pass http://evil;", []string{"add_header", "proxy_pass"}),
+		Entry("with a quote inside a token", "add_header X a'b;\nproxy_pass http://evil;", []string{"add_header", "proxy_pass"}),
+	)
+
+	DescribeTable("allows snippets made of allowed directives",
+		func(snippet string) {
+			Expect(ValidateSnippet(snippet, allowed)).To(Succeed())
+		},
+		Entry("a single statement", "add_header X-A a;"),
+		Entry("a block", "location /a {\n  add_header X-A a;\n}"),
+		Entry("a commented out directive", "add_header X-A a;\n# proxy_pass http://evil;"),
+	)
+
+	DescribeTable("denies snippets hiding other directives",
+		func(snippet string) {
+			Expect(ValidateSnippet(snippet, allowed)).NotTo(Succeed())
+		},
+		Entry("behind a '#' inside a token", "add_header X a#;\naccess_by_lua 'os.execute(\"id\")';"),
+		Entry("behind a quote inside a token", "add_header X a'b;\nproxy_pass http://evil;\nadd_header Y c';"),
+		Entry("in a quoted directive", `"proxy_pass" http://evil;`),
+		Entry("after a quoted string without a space", `add_header X "a"proxy_pass;`),
+		Entry("in an unterminated quoted string", `add_header X "a;`),
+		Entry("in an unterminated statement", "add_header X a"),
+	)
+})
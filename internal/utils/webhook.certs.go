@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// WebhookCertValidity is how long a generated CA and serving certificate last.
+	WebhookCertValidity = 365 * 24 * time.Hour
+	// WebhookCertRenewBefore is how long before expiry both get rotated.
+	WebhookCertRenewBefore = 30 * 24 * time.Hour
+)
+
+// Keys of the admission Secret, named like the kube-webhook-certgen output so
+// the controller's volume mount keeps working.
+const (
+	WebhookSecretCAKey         = "ca"
+	WebhookSecretCertKey       = "cert"
+	WebhookSecretKeyKey        = "key"
+	WebhookSecretPreviousCAKey = "previous-ca"
+)
+
+// AdmissionServiceHosts are the names the ingress-nginx admission Service is reached by.
+func AdmissionServiceHosts(namespace string) []string {
+	return []string{
+		INGRESS_NGINX_CONTROLLER_ADMISSION,
+		INGRESS_NGINX_CONTROLLER_ADMISSION + "." + namespace + ".svc",
+		INGRESS_NGINX_CONTROLLER_ADMISSION + "." + namespace + ".svc.cluster.local",
+	}
+}
+
+// GenerateWebhookCertificates creates a self-signed CA and a serving
+// certificate for hosts signed by it, all PEM encoded.
+func GenerateWebhookCertificates(hosts []string, now time.Time) (caPEM, certPEM, keyPEM []byte, err error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	caSerial, err := randomSerial()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          caSerial,
+		Subject:               pkix.Name{CommonName: "sand-ops-admission-ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(WebhookCertValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(WebhookCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	caPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return caPEM, certPEM, keyPEM, nil
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate in PEM data")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// WebhookCertificateValid reports whether the serving certificate is signed
+// by the CA, covers hosts and isn't due for rotation.
+func WebhookCertificateValid(caPEM, certPEM []byte, hosts []string, now time.Time) bool {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return false
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return false
+	}
+	for _, host := range hosts {
+		_, err := cert.Verify(x509.VerifyOptions{
+			DNSName:     host,
+			Roots:       roots,
+			CurrentTime: now,
+		})
+		if err != nil {
+			return false
+		}
+	}
+	return now.Before(WebhookCertRenewAt(cert))
+}
+
+// WebhookCertRenewAt is when the certificate gets rotated.
+func WebhookCertRenewAt(cert *x509.Certificate) time.Time {
+	return cert.NotAfter.Add(-WebhookCertRenewBefore)
+}
+
+// WebhookCertRenewAtPEM is WebhookCertRenewAt for a PEM encoded certificate.
+func WebhookCertRenewAtPEM(certPEM []byte) (time.Time, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return WebhookCertRenewAt(cert), nil
+}
+
+// WebhookCABundle is the caBundle for the webhook configuration. The previous
+// CA stays trusted until it expires, so pods still serving the old
+// certificate keep working while they roll.
+func WebhookCABundle(caPEM, previousCAPEM []byte, now time.Time) []byte {
+	bundle := append([]byte{}, caPEM...)
+	if previous, err := parseCertificate(previousCAPEM); err == nil && now.Before(previous.NotAfter) {
+		bundle = append(bundle, previousCAPEM...)
+	}
+	return bundle
+}
+
+// CertificateHash fingerprints a certificate for pod template annotations.
+func CertificateHash(certPEM []byte) string {
+	hash := sha256.Sum256(bytes.TrimSpace(certPEM))
+	return hex.EncodeToString(hash[:])[:16]
+}
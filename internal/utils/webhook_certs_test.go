@@ -0,0 +1,95 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/tls"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("webhook certificates", func() {
+	var (
+		hosts                  []string
+		now                    time.Time
+		caPEM, certPEM, keyPEM []byte
+	)
+
+	BeforeEach(func() {
+		hosts = AdmissionServiceHosts("tenant-ns")
+		now = time.Now()
+		var err error
+		caPEM, certPEM, keyPEM, err = GenerateWebhookCertificates(hosts, now)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("generates a serving certificate for every name of the admission Service", func() {
+		_, err := tls.X509KeyPair(certPEM, keyPEM)
+		Expect(err).NotTo(HaveOccurred())
+
+		cert, err := parseCertificate(certPEM)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cert.DNSNames).To(ConsistOf(
+			"ingress-nginx-controller-admission",
+			"ingress-nginx-controller-admission.tenant-ns.svc",
+			"ingress-nginx-controller-admission.tenant-ns.svc.cluster.local",
+		))
+		Expect(cert.NotAfter).To(BeTemporally("~", now.Add(WebhookCertValidity), time.Second))
+
+		ca, err := parseCertificate(caPEM)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ca.IsCA).To(BeTrue())
+		Expect(WebhookCertificateValid(caPEM, certPEM, hosts, now)).To(BeTrue())
+	})
+
+	It("rotates the certificate once it is within the renewal window", func() {
+		renewAt, err := WebhookCertRenewAtPEM(certPEM)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(renewAt).To(BeTemporally("~", now.Add(WebhookCertValidity-WebhookCertRenewBefore), time.Second))
+
+		Expect(WebhookCertificateValid(caPEM, certPEM, hosts, renewAt.Add(-time.Minute))).To(BeTrue())
+		Expect(WebhookCertificateValid(caPEM, certPEM, hosts, renewAt.Add(time.Minute))).To(BeFalse())
+	})
+
+	It("rejects a certificate for other hosts or signed by another CA", func() {
+		Expect(WebhookCertificateValid(caPEM, certPEM, AdmissionServiceHosts("other-ns"), now)).To(BeFalse())
+
+		otherCAPEM, _, _, err := GenerateWebhookCertificates(hosts, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(WebhookCertificateValid(otherCAPEM, certPEM, hosts, now)).To(BeFalse())
+	})
+
+	It("treats a corrupt or empty secret as invalid", func() {
+		Expect(WebhookCertificateValid(nil, nil, hosts, now)).To(BeFalse())
+		Expect(WebhookCertificateValid(caPEM, []byte("not a certificate"), hosts, now)).To(BeFalse())
+		Expect(WebhookCertificateValid([]byte("not a certificate"), certPEM, hosts, now)).To(BeFalse())
+		Expect(WebhookCertificateValid(caPEM, keyPEM, hosts, now)).To(BeFalse())
+		_, err := WebhookCertRenewAtPEM([]byte("not a certificate"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("keeps trusting the previous CA until it expires", func() {
+		previousCAPEM, _, _, err := GenerateWebhookCertificates(hosts, now.Add(-WebhookCertValidity/2))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(WebhookCABundle(caPEM, previousCAPEM, now)).To(Equal(append(append([]byte{}, caPEM...), previousCAPEM...)))
+		Expect(WebhookCABundle(caPEM, previousCAPEM, now.Add(WebhookCertValidity))).To(Equal(caPEM))
+		Expect(WebhookCABundle(caPEM, nil, now)).To(Equal(caPEM))
+	})
+})
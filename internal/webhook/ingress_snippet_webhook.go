@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	controllerapi "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+// IngressSnippetValidatorPath is where the validator is served by the manager's webhook server.
+const IngressSnippetValidatorPath = "/validate-networking-v1-ingress"
+
+// +kubebuilder:webhook:path=/validate-networking-v1-ingress,mutating=false,failurePolicy=ignore,sideEffects=None,groups=networking.k8s.io,resources=ingresses,verbs=create;update,versions=v1,name=vingress-snippets.sandtech.io,admissionReviewVersions=v1
+
+// IngressSnippetValidator rejects snippet annotations on Ingresses served by a
+// tenant ingress controller, unless the tenant's SandOpsIngress enables
+// snippets and every directive is on its allow list.
+//
+// The webhook sees every Ingress of the cluster, so it fails open rather than
+// block them all while the operator is down. The tenant controller still
+// refuses snippet annotations when snippets are disabled.
+type IngressSnippetValidator struct {
+	Client  client.Client
+	Decoder admission.Decoder
+}
+
+func (v *IngressSnippetValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	ingress := &networkingv1.Ingress{}
+	if err := v.Decoder.Decode(req, ingress); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	snippets := map[string]string{}
+	for _, annotation := range utils.SnippetAnnotations {
+		if snippet, ok := ingress.Annotations[annotation]; ok {
+			snippets[annotation] = snippet
+		}
+	}
+	if len(snippets) == 0 {
+		return admission.Allowed("")
+	}
+
+	tenantNamespace, ok := tenantNamespaceForClass(ingressClassName(ingress))
+	if !ok {
+		return admission.Allowed("not served by a tenant ingress controller")
+	}
+
+	tenant := &controllerapi.SandOpsIngress{}
+	if err := v.Client.Get(ctx, utils.IngressNamespacedName(tenantNamespace), tenant); err != nil {
+		if errors.IsNotFound(err) {
+			return admission.Denied(fmt.Sprintf("no SandOpsIngress serves ingress class nginx-%s", tenantNamespace))
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if !utils.SnippetsEnabled(tenant) {
+		return admission.Denied(fmt.Sprintf("snippet annotations are disabled for tenant %s", tenant.Name))
+	}
+
+	allowed := utils.AllowedSnippetDirectives(tenant)
+	for _, annotation := range utils.SortedKeys(snippets) {
+		if err := utils.ValidateAnnotationSnippet(annotation, snippets[annotation], allowed); err != nil {
+			return admission.Denied(fmt.Sprintf("%s: %s", annotation, err))
+		}
+	}
+	return admission.Allowed("")
+}
+
+func ingressClassName(ingress *networkingv1.Ingress) string {
+	if ingress.Spec.IngressClassName != nil {
+		return *ingress.Spec.IngressClassName
+	}
+	return ingress.Annotations["kubernetes.io/ingress.class"]
+}
+
+// tenantNamespaceForClass maps an ingress class of the form nginx-<name>-ns to
+// the namespace of the tenant that owns it.
+func tenantNamespaceForClass(className string) (string, bool) {
+	namespace, ok := strings.CutPrefix(className, "nginx-")
+	if !ok || !strings.HasSuffix(namespace, "-ns") {
+		return "", false
+	}
+	return namespace, true
+}
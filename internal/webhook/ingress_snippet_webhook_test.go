@@ -0,0 +1,109 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	aasdevv1 "sandtech.io/sand-ops/api/v1"
+	"sandtech.io/sand-ops/internal/utils"
+)
+
+var _ = Describe("IngressSnippetValidator", func() {
+	var scheme *runtime.Scheme
+
+	BeforeEach(func() {
+		scheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(aasdevv1.AddToScheme(scheme)).To(Succeed())
+	})
+
+	validateAnnotation := func(tenant *aasdevv1.SandOpsIngress, annotation, snippet string) admission.Response {
+		validator := &IngressSnippetValidator{
+			Client:  fake.NewClientBuilder().WithScheme(scheme).WithObjects(tenant).Build(),
+			Decoder: admission.NewDecoder(scheme),
+		}
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "team-ns",
+				Annotations: map[string]string{
+					annotation: snippet,
+				},
+			},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: utils.DataTypePointerRef("nginx-team-ns"),
+			},
+		}
+		raw, err := json.Marshal(ingress)
+		Expect(err).NotTo(HaveOccurred())
+		return validator.Handle(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Object: runtime.RawExtension{Raw: raw},
+			},
+		})
+	}
+
+	validate := func(tenant *aasdevv1.SandOpsIngress, snippet string) admission.Response {
+		return validateAnnotation(tenant, "nginx.ingress.kubernetes.io/configuration-snippet", snippet)
+	}
+
+	tenant := func(snippets *aasdevv1.SnippetPolicy) *aasdevv1.SandOpsIngress {
+		return &aasdevv1.SandOpsIngress{
+			ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team-ns"},
+			Spec:       aasdevv1.SandOpsIngressSpec{Snippets: snippets},
+		}
+	}
+
+	It("should deny snippets when the tenant has not opted in", func() {
+		response := validate(tenant(nil), `more_set_headers "X-Team: a";`)
+		Expect(response.Allowed).To(BeFalse())
+	})
+
+	It("should allow snippets that only use approved directives", func() {
+		response := validate(tenant(&aasdevv1.SnippetPolicy{Enabled: true}), `more_set_headers "X-Team: a;b"; expires 1h;`)
+		Expect(response.Allowed).To(BeTrue())
+	})
+
+	It("should deny directives outside the allow list, even nested in blocks", func() {
+		response := validate(tenant(&aasdevv1.SnippetPolicy{Enabled: true}), `location /x { content_by_lua_block { os.execute("id") } }`)
+		Expect(response.Allowed).To(BeFalse())
+	})
+
+	It("should use the tenant allow list when one is set", func() {
+		policy := &aasdevv1.SnippetPolicy{Enabled: true, AllowedDirectives: []string{"return"}}
+		Expect(validate(tenant(policy), `return 204;`).Allowed).To(BeTrue())
+		Expect(validate(tenant(policy), `expires 1h;`).Allowed).To(BeFalse())
+	})
+	It("should check the modsecurity snippet like the other snippets", func() {
+		annotation := "nginx.ingress.kubernetes.io/modsecurity-snippet"
+		Expect(validateAnnotation(tenant(nil), annotation, `SecRuleEngine Off`).Allowed).To(BeFalse())
+		policy := &aasdevv1.SnippetPolicy{Enabled: true, AllowedDirectives: []string{"SecRuleEngine"}}
+		Expect(validateAnnotation(tenant(policy), annotation, `SecRuleEngine On`).Allowed).To(BeTrue())
+	})
+})